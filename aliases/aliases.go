@@ -0,0 +1,170 @@
+// Package aliases holds the nickname dictionary (Bill <-> William, Hans <-> Johannes) shared by the
+// dump, which merges it with the aliases of every expert, and the matching, which searches the OneKey
+// first names with their nicknames too
+package aliases
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
+)
+
+// Dictionary maps the first names to their nicknames. The relation is symmetric but not transitive:
+// Chris is a nickname of both Christopher and Christian, which doesn't make them the same name
+type Dictionary struct {
+	// Version identifies the content; the one declared in the file or the checksum of the names
+	Version string
+
+	// lowercased name -> the nicknames as written in the source
+	names map[string][]string
+}
+
+// New returns an empty dictionary; an empty version is replaced with the checksum by Seal
+func New(version string) *Dictionary {
+	return &Dictionary{Version: version, names: map[string][]string{}}
+}
+
+// Add makes every given name a nickname of every other one
+func (d *Dictionary) Add(names ...string) {
+	for _, name := range names {
+		for _, nick := range names {
+			d.add(name, nick)
+		}
+	}
+}
+
+func (d *Dictionary) add(name, nick string) {
+	name, nick = strings.TrimSpace(name), strings.TrimSpace(nick)
+
+	key := strings.ToLower(name)
+	if key == "" || nick == "" || key == strings.ToLower(nick) {
+		return
+	}
+
+	for _, n := range d.names[key] {
+		if strings.EqualFold(n, nick) {
+			return
+		}
+	}
+
+	d.names[key] = append(d.names[key], nick)
+}
+
+// Seal sorts the nicknames and sets the version to the checksum if none has been declared
+func (d *Dictionary) Seal() *Dictionary {
+	keys := []string{}
+	for key, nicks := range d.names {
+		sort.Strings(nicks)
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	if d.Version == "" {
+		h := sha1.New()
+		for _, key := range keys {
+			fmt.Fprintf(h, "%s=%s\n", key, strings.Join(d.names[key], ","))
+		}
+
+		d.Version = hex.EncodeToString(h.Sum(nil))[:12]
+	}
+
+	return d
+}
+
+// Len returns the number of the names with any nickname
+func (d *Dictionary) Len() int {
+	if d == nil {
+		return 0
+	}
+
+	return len(d.names)
+}
+
+// Nicknames returns the nicknames of the name (case insensitive); a nil dictionary has none
+func (d *Dictionary) Nicknames(name string) []string {
+	if d == nil {
+		return nil
+	}
+
+	return d.names[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// Merge adds the nicknames of the name to the aliases of an expert; the duplicates (case insensitive)
+// and the name itself are left out
+func (d *Dictionary) Merge(name string, aliases []string) (merged []string) {
+	seen := map[string]bool{strings.ToLower(name): true}
+
+	for _, alias := range append(append([]string{}, aliases...), d.Nicknames(name)...) {
+		key := strings.ToLower(alias)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		merged = append(merged, alias)
+	}
+
+	return
+}
+
+// file is the JSON form of the dictionary; every group is a set of the names being each other's nicknames
+//
+//	{
+//	  "version": "2019-03",
+//	  "groups": [["William", "Bill", "Will"], ["Johannes", "Hans"]]
+//	}
+type file struct {
+	Version string     `json:"version"`
+	Groups  [][]string `json:"groups"`
+}
+
+// LoadFile reads the dictionary from the JSON file
+func LoadFile(filename string) (*Dictionary, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var content file
+	if err = json.NewDecoder(f).Decode(&content); err != nil {
+		return nil, fmt.Errorf("Nicknames %s couldn't be parsed: %v", filename, err)
+	}
+
+	d := New(content.Version)
+	for _, group := range content.Groups {
+		d.Add(group...)
+	}
+
+	return d.Seal(), nil
+}
+
+// FromMySQL reads the dictionary from the firstname table (name <-> nickname)
+func FromMySQL(mysql modelsMysql.Repository) (*Dictionary, error) {
+	pairs, err := mysql.Nicknames()
+	if err != nil {
+		return nil, err
+	}
+
+	d := New("")
+	for _, pair := range pairs {
+		d.Add(pair[0], pair[1])
+	}
+
+	return d.Seal(), nil
+}
+
+// Load reads the dictionary from the source: "mysql" (or empty) for the firstname table, a JSON file otherwise
+func Load(source string, mysql modelsMysql.Repository) (*Dictionary, error) {
+	if source == "" || source == "mysql" {
+		return FromMySQL(mysql)
+	}
+
+	return LoadFile(source)
+}
@@ -6,10 +6,17 @@ Dumps data from MySQL to Elasticsearch
 */
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
 
+	"github.com/tomekwlod/okpii/aliases"
+	"github.com/tomekwlod/okpii/indexing"
 	modelsES "github.com/tomekwlod/okpii/models/es"
 	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
 	"github.com/tomekwlod/okpii/tools"
@@ -56,22 +63,26 @@ func main() {
 		"countries",
 		"",
 		"Comma separated list of countries, default: all the countries")
+	keepFlag := flag.Int(
+		"keep",
+		2,
+		"Number of the previous index versions kept for a rollback")
+	incrementalFlag := flag.Bool(
+		"incremental",
+		false,
+		"Re-index only the experts changed in MySQL since the last dump and mark the removed ones as deleted")
+	nicknamesFlag := flag.String(
+		"nicknames",
+		"mysql",
+		"Nickname dictionary merged into the aliases: mysql (the firstname table) or a JSON file")
+	rollbackFlag := flag.Bool(
+		"rollback",
+		false,
+		"Move the experts alias back to the previous index version and exit")
 
 	// once done with the flags/arguments let's parse them
 	flag.Parse()
 
-	deployments, err := tools.Deployments(*didFlag)
-	if err != nil {
-		panic(err)
-	}
-	fmt.Printf("\n> Starting with: %v deployment(s)\n", deployments)
-
-	countries, err := tools.Countries(*countriesFlag)
-	if err != nil {
-		panic(err)
-	}
-	fmt.Printf("\n> Countries: %v\n", countries)
-
 	esClient, err := modelsES.ESClient()
 	checkErr(err)
 
@@ -85,40 +96,99 @@ func main() {
 		mysql: mysqlClient,
 	}
 
-	for _, did := range deployments {
-		did, _ := strconv.Atoi(did)
-		fmt.Printf("\nDeployment: %d\n\n", did)
+	// SIGINT/SIGTERM cancels the dump; the unfinished index version is deleted
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		fmt.Printf("\n> Interrupted, stopping the dump\n")
+		cancel()
+	}()
+
+	ix := &indexing.Indexer{
+		ES:     s.es,
+		MySQL:  s.mysql,
+		Batch:  batchInsert,
+		Keep:   *keepFlag,
+		Logger: log.New(os.Stdout, "", 0),
+	}
 
-		fmt.Print("Removing old data...")
-		deleted, err := s.es.RemoveData(did)
-		if err != nil {
-			panic(err)
-		}
-		fmt.Printf(" -> removed %d rows\n\n", deleted)
+	if *rollbackFlag {
+		from, to, err := ix.Rollback(ctx)
+		checkErr(err)
 
-		fmt.Println("Querying for experts")
+		fmt.Printf("\n> Rolled back from %s to %s\n", from, to)
+		return
+	}
 
-		var experts []*modelsMysql.Experts // needs to stay here. If we do below: `err,lastID,experts := s.fetchExperts(...)` it will override id all the time instead of reusing the declared one above
+	deployments, err := tools.Deployments(*didFlag)
+	checkErr(err)
+	fmt.Printf("\n> Starting with: %v deployment(s)\n", deployments)
 
-		lastID := 0
-		for {
-			// getting the experts from the MySQL
-			lastID, experts, err = s.mysql.FetchExperts(lastID, did, batchInsert, countries)
-			checkErr(err)
+	ix.Nicknames, err = aliases.Load(*nicknamesFlag, s.mysql)
+	checkErr(err)
+	fmt.Printf("\n> Nicknames: %d names, version %s\n", ix.Nicknames.Len(), ix.Nicknames.Version)
 
-			quantity := len(experts)
+	ix.Countries, err = tools.Countries(*countriesFlag)
+	checkErr(err)
+	fmt.Printf("\n> Countries: %v\n\n", ix.Countries)
 
-			// stop if no results
-			if quantity == 0 {
-				break
-			}
+	dids := []int{}
+	for _, did := range deployments {
+		did, _ := strconv.Atoi(did)
+		dids = append(dids, did)
+	}
 
-			// indexing the experts onto ES
-			err = s.es.IndexExperts(experts, batchInsert)
+	if *incrementalFlag {
+		report, err := ix.Sync(ctx, dids)
+		if err == context.Canceled {
+			fmt.Printf("\n> Dump cancelled, the deployments not finished will be synced again by the next run\n")
+			os.Exit(1)
+		}
+		if _, ok := err.(*modelsES.BulkError); !ok {
 			checkErr(err)
+		}
 
+		for _, did := range dids {
+			fmt.Printf("> Deployment %d: %d updated, %d deleted\n", did, report.Updated[did], report.Deleted[did])
 		}
+		fmt.Printf("> %s\n", report.Stats)
+
+		exitOnFailures(err, "the deployments with failures will be synced again by the next run")
+		return
+	}
+
+	report, err := ix.Dump(ctx, dids)
+	if err == context.Canceled {
+		fmt.Printf("\n> Dump cancelled, the alias still points to the previous version\n")
+		os.Exit(1)
 	}
+	exitOnFailures(err, "the alias still points to the previous version")
+	checkErr(err)
+
+	fmt.Printf("\n> %s is live (previous: %s)\n", report.Version, report.Previous)
+	fmt.Printf("> %s\n", report.Stats)
+	for _, v := range report.Pruned {
+		fmt.Printf("> %s deleted\n", v)
+	}
+}
+
+// exitOnFailures lists the experts ES refused to index and exits with 1
+func exitOnFailures(err error, consequence string) {
+	be, ok := err.(*modelsES.BulkError)
+	if !ok {
+		return
+	}
+
+	fmt.Printf("\n> %d experts couldn't be indexed; %s\n", len(be.Failures), consequence)
+	for _, f := range be.Failures {
+		fmt.Printf("  %s [%d] %s\n", f.ID, f.Status, f.Reason)
+	}
+
+	os.Exit(1)
 }
 
 func checkErr(err error) {
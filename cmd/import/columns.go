@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// columns maps the canonical fields onto the candidate columns of the file; the first candidate
+// found in the header is used
+//
+//	{
+//	  "SRC_CUST_ID": ["ONEKEY_ID", "SRC_CUST_ID"],
+//	  "CNTRY": ["COUNTRY", "CNTRY"],
+//	  "SPECIALTY": ["SPECIALTY_1"]
+//	}
+type columns map[string][]string
+
+// required are the fields every extract has to provide
+var required = []string{"SRC_CUST_ID", "CUST_NAME", "FIRST_NAME", "LAST_NAME", "CITY", "CNTRY"}
+
+// optional are the fields imported when found; the matching doesn't need them
+var optional = []string{"MIDDLE_NAME", "SPECIALTY", "POSTCODE", "WORKPLACE"}
+
+// defaultColumns are the columns of the standard extract plus the usual names of the optional ones
+func defaultColumns() columns {
+	c := columns{}
+	for _, field := range append(append([]string{}, required...), optional...) {
+		c[field] = []string{field}
+	}
+
+	c["SPECIALTY"] = append(c["SPECIALTY"], "SPECIALTY_1", "SPEC_DESC")
+	c["POSTCODE"] = append(c["POSTCODE"], "POSTAL_CODE", "ZIP")
+	c["WORKPLACE"] = append(c["WORKPLACE"], "WORKPLACE_NAME", "WKP_NAME")
+
+	return c
+}
+
+// load replaces the candidates of the fields given in the JSON file
+func (c columns) load(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	mapping := columns{}
+	if err = json.NewDecoder(f).Decode(&mapping); err != nil {
+		return fmt.Errorf("Columns %s couldn't be parsed: %v", filename, err)
+	}
+
+	for field, candidates := range mapping {
+		if err = c.set(field, candidates); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parse replaces the candidates of the fields given in the form of "CNTRY=COUNTRY|LAND,CITY=TOWN"
+func (c columns) parse(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("Column mapping %s should be in the form of FIELD=COLUMN|COLUMN", pair)
+		}
+
+		if err := c.set(strings.TrimSpace(kv[0]), strings.Split(kv[1], "|")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c columns) set(field string, candidates []string) error {
+	if _, ok := c[field]; !ok {
+		return fmt.Errorf("Unknown field %s; the fields are: %s", field, strings.Join(append(append([]string{}, required...), optional...), ", "))
+	}
+
+	c[field] = nil
+	for _, candidate := range candidates {
+		if candidate = strings.TrimSpace(candidate); candidate != "" {
+			c[field] = append(c[field], candidate)
+		}
+	}
+
+	return nil
+}
+
+// resolve returns the position of every field found in the header; a missing required field is an error
+func (c columns) resolve(headers []string) (positions map[string]int, err error) {
+	positions = map[string]int{}
+
+	missing := []string{}
+	for _, field := range append(append([]string{}, required...), optional...) {
+		for _, candidate := range c[field] {
+			if i := indexOf(headers, candidate); i >= 0 {
+				positions[field] = i
+				break
+			}
+		}
+
+		if _, ok := positions[field]; !ok && isRequired(field) {
+			missing = append(missing, fmt.Sprintf("%s (%s)", field, strings.Join(c[field], "|")))
+		}
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("Missing columns: %s", strings.Join(missing, ", "))
+	}
+
+	return
+}
+
+// describe lists the columns the fields are read from, eg. "CNTRY <- COUNTRY"
+func describe(headers []string, positions map[string]int) (lines []string) {
+	for _, field := range append(append([]string{}, required...), optional...) {
+		if i, ok := positions[field]; ok {
+			lines = append(lines, fmt.Sprintf("%s <- %s", field, headers[i]))
+		}
+	}
+
+	return
+}
+
+func isRequired(field string) bool {
+	return indexOf(required, field) >= 0
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return i
+		}
+	}
+
+	return -1
+}
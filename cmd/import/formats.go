@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/smartystreets/scanners/csv"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// input describes the OneKey file; the gzipped files are detected by their content
+type input struct {
+	filename string
+
+	// format is csv, tsv, xlsx, fixed or auto
+	format string
+
+	// delimiter of the csv; 0 detects it from the header line
+	delimiter rune
+
+	// encoding of the text formats: utf-8, latin1 or windows-1252
+	encoding string
+
+	// widths of the columns of the fixed-width format in characters
+	widths []int
+}
+
+// the delimiters tried when the csv delimiter isn't given
+var delimiters = []rune{',', ';', '\t', '|'}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// parseDelimiter accepts a single character, "tab" or "\t"
+func parseDelimiter(s string) (rune, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+
+	if utf8.RuneCountInString(s) != 1 {
+		return 0, fmt.Errorf("Delimiter %s should be a single character", s)
+	}
+
+	r, _ := utf8.DecodeRuneInString(s)
+
+	return r, nil
+}
+
+// parseWidths parses the column widths in the form of "12,30,30"
+func parseWidths(s string) (widths []int, err error) {
+	for _, w := range strings.Split(s, ",") {
+		if strings.TrimSpace(w) == "" {
+			continue
+		}
+
+		width, err := strconv.Atoi(strings.TrimSpace(w))
+		if err != nil || width < 1 {
+			return nil, fmt.Errorf("Width %s should be a positive number", w)
+		}
+
+		widths = append(widths, width)
+	}
+
+	return
+}
+
+// decoder returns the reader decoding the text from the encoding to UTF-8
+func decoder(r io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(encoding) {
+	case "", "utf-8", "utf8":
+		return r, nil
+	case "latin1", "latin-1", "iso-8859-1":
+		return charmap.ISO8859_1.NewDecoder().Reader(r), nil
+	case "windows-1252", "cp1252":
+		return charmap.Windows1252.NewDecoder().Reader(r), nil
+	}
+
+	return nil, fmt.Errorf("Encoding %s not supported; use utf-8, latin1 or windows-1252", encoding)
+}
+
+// readRecords streams the records of the file (the header first) to out, whatever the format
+func readRecords(in input, out chan<- []string) {
+	defer close(out)
+
+	f, err := os.Open(in.filename)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReader(f)
+
+	name := in.filename
+	if magic, _ := r.(*bufio.Reader).Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			panic(err)
+		}
+		defer gz.Close()
+
+		r = bufio.NewReader(gz)
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	format := in.format
+	if format == "" || format == "auto" {
+		format = detect(r.(*bufio.Reader), name, in.widths)
+		fmt.Printf("Format: %s\n", format)
+	}
+
+	// the spreadsheets are always UTF-8 (XML)
+	if format != "xlsx" {
+		if r, err = decoder(r, in.encoding); err != nil {
+			panic(err)
+		}
+	}
+
+	switch format {
+	case "csv", "tsv":
+		delimiter := in.delimiter
+		if format == "tsv" {
+			delimiter = '\t'
+		}
+
+		br := bufio.NewReader(r)
+		if delimiter == 0 {
+			delimiter = sniff(br)
+			fmt.Printf("Delimiter: %q\n", delimiter)
+		}
+
+		err = csvRecords(br, delimiter, out)
+	case "fixed":
+		err = fixedRecords(r, in.widths, out)
+	case "xlsx":
+		err = xlsxRecords(r, out)
+	default:
+		err = fmt.Errorf("Format %s not supported; use csv, tsv, xlsx, fixed or auto", format)
+	}
+
+	if err != nil {
+		panic(err)
+	}
+}
+
+// detect tells the format by the content (xlsx is a zip), the widths or the file extension
+func detect(r *bufio.Reader, name string, widths []int) string {
+	if magic, _ := r.Peek(len(zipMagic)); bytes.Equal(magic, zipMagic) {
+		return "xlsx"
+	}
+
+	if len(widths) > 0 {
+		return "fixed"
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".tsv", ".tab":
+		return "tsv"
+	}
+
+	return "csv"
+}
+
+// sniff picks the delimiter occurring most often in the header line
+func sniff(r *bufio.Reader) rune {
+	line, _ := r.Peek(4096)
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+
+	best, count := delimiters[0], 0
+	for _, d := range delimiters {
+		if c := strings.Count(string(line), string(d)); c > count {
+			best, count = d, c
+		}
+	}
+
+	return best
+}
+
+func csvRecords(r io.Reader, delimiter rune, out chan<- []string) error {
+	scanner := csv.NewScanner(r,
+		csv.Comma(delimiter), csv.Comment('#'), csv.ContinueOnError(true))
+
+	for scanner.Scan() {
+		if err := scanner.Error(); err != nil {
+			fmt.Fprintln(os.Stderr, "reading the file:", err)
+		} else if record := scanner.Record(); !empty(record) {
+			out <- record
+		}
+	}
+
+	return nil
+}
+
+// fixedRecords cuts every line into the columns of the given widths; the columns are trimmed
+func fixedRecords(r io.Reader, widths []int, out chan<- []string) error {
+	if len(widths) == 0 {
+		return fmt.Errorf("The fixed-width format needs the column widths (-widths)")
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := []rune(strings.TrimRight(scanner.Text(), "\r"))
+		if len(line) == 0 {
+			continue
+		}
+
+		record := make([]string, 0, len(widths))
+		start := 0
+		for _, w := range widths {
+			end := start + w
+			if start > len(line) {
+				start = len(line)
+			}
+			if end > len(line) {
+				end = len(line)
+			}
+
+			record = append(record, strings.TrimSpace(string(line[start:end])))
+			start += w
+		}
+
+		if !empty(record) {
+			out <- record
+		}
+	}
+
+	return scanner.Err()
+}
+
+// empty tells if all the cells of the record are blank, eg. a line of delimiters only or a formatted
+// but empty row of a spreadsheet; such records are skipped
+func empty(record []string) bool {
+	for _, cell := range record {
+		if strings.TrimSpace(cell) != "" {
+			return false
+		}
+	}
+
+	return true
+}
@@ -6,21 +6,21 @@ Imports a CSV file into MongoDB database
 */
 
 import (
-	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path"
 	"strings"
 	"time"
 
-	"github.com/smartystreets/scanners/csv"
-
 	"github.com/tomekwlod/utils"
 
 	"github.com/mongodb/mongo-go-driver/bson"
 	"github.com/mongodb/mongo-go-driver/mongo"
 
+	"github.com/tomekwlod/okpii/country"
 	modelsMongodb "github.com/tomekwlod/okpii/models/mongodb"
+	"github.com/tomekwlod/okpii/names"
 )
 
 /*
@@ -31,7 +31,6 @@ of the CSV file so it should in theory import every file)
 
 @todo: check how the empty lines in CSV will be dealt
 @todo: the db/collection values are hardcoded! changeme!!
-@todo: file source is also hardcoded!! changeme!!
 @todo: Clean() should be exported as a util, outside of this command!
 
 Nice article about dealing with huuuge CSV files and combining the lines one by one
@@ -69,6 +68,64 @@ func Clean(b []byte) []byte {
 }
 
 func main() {
+	columnsFlag := flag.String(
+		"columns",
+		"",
+		"JSON file mapping the fields onto the candidate columns of the file, eg. {\"CNTRY\": [\"COUNTRY\", \"CNTRY\"]}")
+	mapFlag := flag.String(
+		"map",
+		"",
+		"Comma separated field mapping applied after -columns, eg. CNTRY=COUNTRY|LAND,SPECIALTY=SPEC_1")
+	fileFlag := flag.String(
+		"file",
+		"",
+		"OneKey file, gzipped or not (default: $STATICPATH/file.csv)")
+	formatFlag := flag.String(
+		"format",
+		"auto",
+		"Format of the file: csv, tsv, xlsx, fixed or auto (by the content and the extension)")
+	delimiterFlag := flag.String(
+		"delimiter",
+		"",
+		"Delimiter of the csv, eg. ; or tab (default: detected from the header line)")
+	encodingFlag := flag.String(
+		"encoding",
+		"utf-8",
+		"Encoding of the csv, tsv and fixed-width files: utf-8, latin1 or windows-1252")
+	widthsFlag := flag.String(
+		"widths",
+		"",
+		"Comma separated widths of the columns of the fixed-width file in characters, eg. 12,40,20,20,30,20")
+
+	// once done with the flags/arguments let's parse them
+	flag.Parse()
+
+	cols := defaultColumns()
+	if *columnsFlag != "" {
+		if err := cols.load(*columnsFlag); err != nil {
+			panic(err)
+		}
+	}
+	if err := cols.parse(*mapFlag); err != nil {
+		panic(err)
+	}
+
+	in := input{format: *formatFlag, encoding: *encodingFlag}
+
+	var err error
+	if in.delimiter, err = parseDelimiter(*delimiterFlag); err != nil {
+		panic(err)
+	}
+	if in.widths, err = parseWidths(*widthsFlag); err != nil {
+		panic(err)
+	}
+	if in.format == "fixed" && len(in.widths) == 0 {
+		panic("-format=fixed needs the column widths (-widths)")
+	}
+	// checking the encoding before anything is removed from MongoDB
+	if _, err = decoder(nil, in.encoding); err != nil {
+		panic(err)
+	}
 
 	if os.Getenv("STATICPATH") == "" {
 		// in prod mode (with the docker) the STATICPATH won't be empty
@@ -76,7 +133,10 @@ func main() {
 		// in dev mode set the default static path
 		os.Setenv("STATICPATH", "../../data/static")
 	}
-	filename := path.Join(os.Getenv("STATICPATH"), onekyfn)
+	filename := *fileFlag
+	if filename == "" {
+		filename = path.Join(os.Getenv("STATICPATH"), onekyfn)
+	}
 
 	// export below as a RequireFile(filename string) function
 	for {
@@ -101,6 +161,7 @@ func main() {
 	}
 
 	fmt.Printf("\nFile %s looks ok! Processing...\n\n", filename)
+	in.filename = filename
 
 	t1 := time.Now()
 
@@ -115,6 +176,25 @@ func main() {
 		mongo: db,
 	}
 
+	ch := make(chan []string) // one record, whatever the format
+	go readRecords(in, ch)
+
+	// the header is checked against the mapping before anything is removed from MongoDB
+	line, ok := <-ch
+	if !ok {
+		panic("File " + filename + " is empty")
+	}
+
+	headers, positions, err := validateHeader(line, cols)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, l := range describe(headers, positions) {
+		fmt.Println(l)
+	}
+	fmt.Println()
+
 	fmt.Println("Removing the old data")
 	rowsDeleted, err := s.mongo.ClearCollection()
 	if err != nil {
@@ -122,26 +202,41 @@ func main() {
 	}
 	fmt.Printf("\n%d rows deleted from MongoDB\n\n", rowsDeleted)
 
-	ch := make(chan []string) // one line from csv
-	go csvReader(filename, ch)
-
 	var operations []mongo.WriteModel
-	headers := []string{}
-	headersLine := true
+	unknown := map[string]int{} // country -> rows
+	noID := 0
 
 	for line := range ch {
-		// this happens only once for the headers line
-		if headersLine {
-			headers, err = validateHeader(line)
-			if err != nil {
-				panic(err)
-			}
+		row := consolidateWithHeader(headers, positions, line)
 
-			headersLine = false
+		// the rows are upserted by the id; the ones without it would overwrite each other
+		if strings.TrimSpace(row["SRC_CUST_ID"]) == "" {
+			noID++
 			continue
 		}
 
-		row := consolidateWithHeader(headers, line)
+		// the countries are stored with the names used by SciIQ no matter how OneKey provides them
+		if name, err := country.Name(row["CNTRY"]); err == nil {
+			row["CNTRY"] = name
+		} else if row["CNTRY"] != "" {
+			unknown[row["CNTRY"]]++
+		}
+
+		// the names are stored without the titles and the suffixes and with the particles in the last name;
+		// the name as delivered is kept in NAME_ORIGINAL
+		n := names.Parse(row["FIRST_NAME"], row["MIDDLE_NAME"], row["LAST_NAME"])
+		row["NAME_ORIGINAL"] = n.Original
+		row["FIRST_NAME"] = n.Fn
+		row["LAST_NAME"] = n.Ln
+		if _, ok := row["MIDDLE_NAME"]; ok {
+			row["MIDDLE_NAME"] = n.Mn
+		}
+		if len(n.Titles) > 0 {
+			row["TITLES"] = strings.Join(n.Titles, " ")
+		}
+		if n.Suffix != "" {
+			row["NAME_SUFFIX"] = n.Suffix
+		}
 
 		t := true
 		operation := mongo.NewReplaceOneModel()
@@ -167,37 +262,22 @@ func main() {
 		panic(err)
 	}
 
-	t2 := time.Now()
-
-	fmt.Printf("All done in: %v \n", t2.Sub(t1))
-
-}
-
-func csvReader(fname string, out chan<- []string) {
-	defer close(out)
+	if noID > 0 {
+		fmt.Printf("%d rows without SRC_CUST_ID skipped\n", noID)
+	}
 
-	// Load a TXT file.
-	f, err := os.Open(fname)
-	if err != nil {
-		panic(err)
+	for c, rows := range unknown {
+		fmt.Printf("Country %s not recognised (%d rows); these rows won't be matched\n", c, rows)
 	}
-	defer f.Close()
 
-	scanner := csv.NewScanner(f,
-		csv.Comma(','), csv.Comment('#'), csv.ContinueOnError(true))
+	t2 := time.Now()
 
-	for scanner.Scan() {
-		if err := scanner.Error(); err != nil {
-			fmt.Fprintln(os.Stderr, "reading standard input:", err)
-		} else {
-			out <- scanner.Record()
-		}
-	}
-}
+	fmt.Printf("All done in: %v \n", t2.Sub(t1))
 
-func validateHeader(headers []string) (newHeaders []string, err error) {
-	required := []string{"SRC_CUST_ID", "CUST_NAME", "FIRST_NAME", "LAST_NAME", "CITY", "CNTRY"}
+}
 
+// validateHeader cleans the header and finds the columns of the fields; all the required fields have to be found
+func validateHeader(headers []string, cols columns) (newHeaders []string, positions map[string]int, err error) {
 	for _, header := range headers {
 		cl := Clean([]byte(header))
 		header = string(cl)
@@ -205,37 +285,26 @@ func validateHeader(headers []string) (newHeaders []string, err error) {
 		newHeaders = append(newHeaders, header)
 	}
 
-	missing := []string{}
-	for _, field := range required {
-		found := false
-
-		for _, header := range newHeaders {
-			if header == field {
-				// it's ok, break the sub-for
-				found = true
-
-				break
-			}
-		}
-
-		if !found {
-			missing = append(missing, field)
-		}
-	}
-
-	if len(missing) > 0 {
-		err = errors.New("Missing columns: " + strings.Join(missing, ", "))
-	}
+	positions, err = cols.resolve(newHeaders)
 
 	return
 }
 
-// Combines header with the lines and as an output we have ["column1" => "value1", "column2" => "value2"]
-func consolidateWithHeader(headers, line []string) map[string]string {
+// Combines header with the lines and as an output we have ["column1" => "value1", "column2" => "value2"];
+// the fields are set from their columns on top, so the rows always have the canonical fields
+func consolidateWithHeader(headers []string, positions map[string]int, line []string) map[string]string {
 	m := map[string]string{}
 
 	for i, h := range headers {
-		m[h] = line[i]
+		if i < len(line) {
+			m[h] = line[i]
+		}
+	}
+
+	for field, i := range positions {
+		if i < len(line) {
+			m[field] = line[i]
+		}
 	}
 
 	return m
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+/*
+The xlsx is a zip of XML files; only the first sheet is read. The zip needs to be read from the end so the
+file is copied to a temporary file first (the input can be gzipped or a pipe); the rows are streamed from the sheet
+
+	xl/workbook.xml             the sheets and their relation ids
+	xl/_rels/workbook.xml.rels  the relation id -> the sheet file
+	xl/sharedStrings.xml        the texts, referenced by the cells of type "s"
+	xl/worksheets/sheet1.xml    <row><c r="B1" t="s"><v>0</v></c></row>
+*/
+
+func xlsxRecords(r io.Reader, out chan<- []string) error {
+	f, err := ioutil.TempFile("", "okpii-import-*.xlsx")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+
+	z, err := zip.NewReader(f, size)
+	if err != nil {
+		return fmt.Errorf("Not an xlsx file: %v", err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range z.File {
+		files[f.Name] = f
+	}
+
+	sheet, err := firstSheet(files)
+	if err != nil {
+		return err
+	}
+
+	strs, err := sharedStrings(files["xl/sharedStrings.xml"])
+	if err != nil {
+		return err
+	}
+
+	rc, err := sheet.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return sheetRows(xml.NewDecoder(rc), strs, out)
+}
+
+// firstSheet finds the file of the first sheet of the workbook
+func firstSheet(files map[string]*zip.File) (*zip.File, error) {
+	var workbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+
+	if err := decodeFile(files["xl/workbook.xml"], &workbook); err != nil {
+		return nil, err
+	}
+	if err := decodeFile(files["xl/_rels/workbook.xml.rels"], &rels); err != nil {
+		return nil, err
+	}
+
+	if len(workbook.Sheets) > 0 {
+		for _, rel := range rels.Relationships {
+			if rel.ID != workbook.Sheets[0].ID {
+				continue
+			}
+
+			// relative to xl/ or absolute within the zip
+			name := path.Join("xl", rel.Target)
+			if strings.HasPrefix(rel.Target, "/") {
+				name = strings.TrimPrefix(rel.Target, "/")
+			}
+
+			if f, ok := files[name]; ok {
+				return f, nil
+			}
+		}
+	}
+
+	// the default name, eg. for the strict OOXML workbooks
+	if f, ok := files["xl/worksheets/sheet1.xml"]; ok {
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("No sheet found in the xlsx file")
+}
+
+// sharedStrings returns the texts of the workbook; a rich text is joined from its runs
+func sharedStrings(f *zip.File) (strs []string, err error) {
+	if f == nil {
+		// a workbook with the inline strings only
+		return
+	}
+
+	var sst struct {
+		Items []struct {
+			T    string   `xml:"t"`
+			Runs []string `xml:"r>t"`
+		} `xml:"si"`
+	}
+
+	if err = decodeFile(f, &sst); err != nil {
+		return
+	}
+
+	for _, si := range sst.Items {
+		strs = append(strs, si.T+strings.Join(si.Runs, ""))
+	}
+
+	return
+}
+
+func decodeFile(f *zip.File, v interface{}) error {
+	if f == nil {
+		return fmt.Errorf("Not an xlsx file: the workbook is missing")
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// sheetRows streams the rows of the sheet; the empty cells (missing in the XML) are filled with "" and
+// the empty rows (eg. only formatted) are skipped
+func sheetRows(d *xml.Decoder, strs []string, out chan<- []string) error {
+	type cell struct {
+		Ref    string `xml:"r,attr"`
+		Type   string `xml:"t,attr"`
+		Value  string `xml:"v"`
+		Inline string `xml:"is>t"`
+	}
+
+	var row []string
+
+	for {
+		token, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = []string{}
+			case "c":
+				var c cell
+				if err = d.DecodeElement(&c, &t); err != nil {
+					return err
+				}
+
+				value := c.Value
+				switch c.Type {
+				case "s":
+					i, err := strconv.Atoi(c.Value)
+					if err != nil || i < 0 || i >= len(strs) {
+						return fmt.Errorf("Cell %s refers to a missing text %s", c.Ref, c.Value)
+					}
+					value = strs[i]
+				case "inlineStr":
+					value = c.Inline
+				}
+
+				if i := column(c.Ref); i >= 0 {
+					for len(row) < i {
+						row = append(row, "")
+					}
+				}
+				row = append(row, value)
+			}
+		case xml.EndElement:
+			if t.Name.Local == "row" && !empty(row) {
+				out <- row
+			}
+		}
+	}
+}
+
+// column returns the 0-based column of the cell reference, eg. "B12" -> 1; -1 without the reference
+func column(ref string) int {
+	i := 0
+	n := 0
+	for ; n < len(ref) && ref[n] >= 'A' && ref[n] <= 'Z'; n++ {
+		i = i*26 + int(ref[n]-'A'+1)
+	}
+
+	return i - 1
+}
@@ -0,0 +1,143 @@
+package main
+
+/*
+MAPPING
+Compares the mapping of the experts index with the one in the binary and migrates the index
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tomekwlod/okpii/indexing"
+	modelsES "github.com/tomekwlod/okpii/models/es"
+	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const usage = `Usage: mapping [flags] status|diff|apply
+
+  status  prints the mapping version of the binary and of the live index
+  diff    lists the fields added, removed and changed in the binary
+  apply   migrates the live index to the mapping of the binary (a no-op without a drift, see -force)
+
+`
+
+func main() {
+	keepFlag := flag.Int(
+		"keep",
+		2,
+		"Number of the previous index versions kept for a rollback")
+	forceFlag := flag.Bool(
+		"force",
+		false,
+		"Migrate the index even if no drift is found")
+	jsonFlag := flag.Bool(
+		"json",
+		false,
+		"Print the diff as JSON")
+
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+
+	// once done with the flags/arguments let's parse them
+	flag.Parse()
+
+	cmd := flag.Arg(0)
+	if cmd != "status" && cmd != "diff" && cmd != "apply" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	esClient, err := modelsES.ESClient()
+	checkErr(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		fmt.Printf("\n> Interrupted, stopping\n")
+		cancel()
+	}()
+
+	diff, err := esClient.MappingDiff(ctx)
+	checkErr(err)
+
+	switch cmd {
+	case "status":
+		fmt.Printf("\nIndex: %s\nMapping version: %d (live: %d)\n", diff.Index, diff.Version, diff.LiveVersion)
+		if diff.Drift() {
+			fmt.Printf("Drift: %d added, %d removed, %d changed fields; see `mapping diff`\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+			os.Exit(1)
+		}
+		fmt.Println("No drift")
+
+	case "diff":
+		if *jsonFlag {
+			checkErr(json.NewEncoder(os.Stdout).Encode(diff))
+		} else {
+			printDiff(diff)
+		}
+		if diff.Drift() {
+			os.Exit(1)
+		}
+
+	case "apply":
+		if !diff.Drift() && !*forceFlag {
+			fmt.Println("\nNo drift, nothing to apply")
+			return
+		}
+		printDiff(diff)
+
+		mysqlClient, err := modelsMysql.MysqlClient()
+		checkErr(err)
+		defer mysqlClient.Close()
+
+		ix := &indexing.Indexer{
+			ES:     esClient,
+			MySQL:  mysqlClient,
+			Keep:   *keepFlag,
+			Logger: log.New(os.Stdout, "", 0),
+		}
+
+		report, err := ix.Migrate(ctx)
+		checkErr(err)
+
+		fmt.Printf("\n> %s is live with the mapping version %d (previous: %s)\n", report.Version, diff.Version, report.Previous)
+		for _, v := range report.Pruned {
+			fmt.Printf("> %s deleted\n", v)
+		}
+	}
+}
+
+func printDiff(diff *modelsES.MappingDiff) {
+	fmt.Printf("\nIndex: %s, mapping version %d -> %d\n", diff.Index, diff.LiveVersion, diff.Version)
+
+	for _, f := range diff.Added {
+		fmt.Printf("+ %s\n", f)
+	}
+	for _, f := range diff.Removed {
+		fmt.Printf("- %s\n", f)
+	}
+	for _, f := range diff.Changed {
+		fmt.Printf("~ %s\n", f)
+	}
+}
+
+func checkErr(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
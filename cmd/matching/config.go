@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+
+	"github.com/tomekwlod/okpii/matching"
+)
+
+// loadConfig builds the matching configuration from the optional file and the flags; the flags win
+func loadConfig(filename, collectAll, cityAware, strategies, disabled, maxResults string) (cfg matching.Config, err error) {
+	cfg = matching.DefaultConfig()
+
+	if filename != "" {
+		cfg, err = matching.LoadConfig(filename)
+		if err != nil {
+			return
+		}
+	}
+
+	// the bool flags override the file only if they were given explicitly
+	flag.Visit(func(f *flag.Flag) {
+		if err != nil {
+			return
+		}
+
+		switch f.Name {
+		case "collect-all":
+			cfg.CollectAll, err = strconv.ParseBool(collectAll)
+		case "city":
+			cfg.CityAware, err = strconv.ParseBool(cityAware)
+		}
+	})
+	if err != nil {
+		return
+	}
+
+	if strategies != "" {
+		cfg.Strategies = split(strategies)
+	}
+
+	if disabled != "" {
+		cfg.Disabled = append(cfg.Disabled, split(disabled)...)
+	}
+
+	if maxResults != "" {
+		err = cfg.ParseMaxResults(maxResults)
+	}
+
+	return
+}
+
+func split(s string) (result []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
+)
+
+const (
+	linkAdded   = "added"
+	linkRemoved = "removed"
+	linkChanged = "changed"
+)
+
+// linkDiff is a single difference between the computed and the stored OneKey links
+type linkDiff struct {
+	Did    int    `json:"did"`
+	Onekey string `json:"onekey"`
+	Change string `json:"change"`
+	Old    []int  `json:"old"`
+	New    []int  `json:"new"`
+}
+
+// diffSink collects the computed links and compares them with the ones stored in kol__onekey on close.
+// Only the OneKey rows processed in this run are compared
+type diffSink struct {
+	mysql  modelsMysql.Repository
+	report *reportSink
+
+	// did -> onekey -> kol ids
+	computed map[int]map[string][]int
+}
+
+func newDiffSink(mysql modelsMysql.Repository, filename string) (*diffSink, error) {
+	report, err := newReportSink(filename, []string{"did", "onekey", "change", "old", "new"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &diffSink{mysql: mysql, report: report, computed: map[int]map[string][]int{}}, nil
+}
+
+func (s *diffSink) write(onekey string, did int, results []*modelsMysql.MatchResult) error {
+	if _, ok := s.computed[did]; !ok {
+		s.computed[did] = map[string][]int{}
+	}
+
+	kids := []int{}
+	for _, r := range results {
+		kids = append(kids, r.KID)
+	}
+
+	s.computed[did][onekey] = append(s.computed[did][onekey], kids...)
+
+	return nil
+}
+
+// ambiguous passes the ambiguity to the report; the stored links are not affected by it
+func (s *diffSink) ambiguous(a *modelsMysql.Ambiguity) error {
+	return s.report.ambiguous(a)
+}
+
+func (s *diffSink) flush() error {
+	return nil
+}
+
+func (s *diffSink) close() error {
+	dids := []int{}
+	for did := range s.computed {
+		dids = append(dids, did)
+	}
+	sort.Ints(dids)
+
+	fmt.Printf("\n%-6s %10s %10s %10s\n", "DID", "ADDED", "REMOVED", "CHANGED")
+
+	for _, did := range dids {
+		stored, err := s.mysql.OnekeyLinks(did)
+		if err != nil {
+			return err
+		}
+
+		diffs := diffLinks(did, s.computed[did], stored)
+
+		counts := map[string]int{}
+		for _, d := range diffs {
+			counts[d.Change]++
+
+			if err = s.writeDiff(d); err != nil {
+				return err
+			}
+		}
+
+		fmt.Printf("%-6d %10d %10d %10d\n", did, counts[linkAdded], counts[linkRemoved], counts[linkChanged])
+	}
+
+	return s.report.close()
+}
+
+func (s *diffSink) writeDiff(d linkDiff) error {
+	if s.report.json != nil {
+		return s.report.json.Encode(d)
+	}
+
+	return s.report.csv.Write([]string{strconv.Itoa(d.Did), d.Onekey, d.Change, joinInts(d.Old), joinInts(d.New)})
+}
+
+// diffLinks compares the computed links with the stored ones for the OneKeys which have been computed
+func diffLinks(did int, computed, stored map[string][]int) (diffs []linkDiff) {
+	onekeys := []string{}
+	for onekey := range computed {
+		onekeys = append(onekeys, onekey)
+	}
+	sort.Strings(onekeys)
+
+	for _, onekey := range onekeys {
+		newIDs := unique(computed[onekey])
+		oldIDs := unique(stored[onekey])
+
+		switch {
+		case len(oldIDs) == 0 && len(newIDs) == 0:
+			continue
+		case len(oldIDs) == 0:
+			diffs = append(diffs, linkDiff{did, onekey, linkAdded, oldIDs, newIDs})
+		case len(newIDs) == 0:
+			diffs = append(diffs, linkDiff{did, onekey, linkRemoved, oldIDs, newIDs})
+		case joinInts(oldIDs) != joinInts(newIDs):
+			diffs = append(diffs, linkDiff{did, onekey, linkChanged, oldIDs, newIDs})
+		}
+	}
+
+	return
+}
+
+// unique returns sorted ids without the duplicates (kol__onekey may contain them)
+func unique(ids []int) (result []int) {
+	set := map[int]bool{}
+	for _, id := range ids {
+		if !set[id] {
+			set[id] = true
+			result = append(result, id)
+		}
+	}
+	sort.Ints(result)
+
+	return
+}
+
+func joinInts(ids []int) string {
+	s := []string{}
+	for _, id := range ids {
+		s = append(s, strconv.Itoa(id))
+	}
+
+	return strings.Join(s, ",")
+}
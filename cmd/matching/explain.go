@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/tomekwlod/okpii/matching"
+)
+
+// explanation is printed by -explain
+type explanation struct {
+	Onekey      string                `json:"onekey"`
+	Input       matching.Input        `json:"input"`
+	Matches     []matching.Match      `json:"matches"`
+	Ambiguities []matching.Ambiguity  `json:"ambiguities"`
+	Explain     *matching.Explanation `json:"explain"`
+}
+
+// explain runs the pipeline for a single OneKey row and prints everything it did as JSON; nothing is stored
+func explain(s *service, onekey string, dids []int, timeout time.Duration) error {
+	m, err := s.mongo.Onekey(onekey)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("OneKey %s not found", onekey)
+	}
+
+	fn, mn, ln := onekeyNames(m)
+	id, _ := strconv.Atoi(m["SRC_CUST_ID"])
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	ctx, ex := matching.WithExplain(ctx)
+
+	result, err := s.findMatches(ctx, dids, id, m["CUST_NAME"], m["CNTRY"], m["CITY"], fn, mn, ln)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(explanation{
+		Onekey: onekey,
+		Input: matching.Input{
+			CustName: m["CUST_NAME"],
+			Fn:       fn,
+			Mn:       mn,
+			Ln:       ln,
+			Country:  m["CNTRY"],
+			City:     m["CITY"],
+			Dids:     dids,
+		},
+		Matches:     result.Matches,
+		Ambiguities: result.Ambiguities,
+		Explain:     ex,
+	})
+}
@@ -10,47 +10,51 @@ Kai Hübel <-- in onkey
 1267961: Kai Hübel <-- mysql
 
 
-Problem.1.
-Below matching works per deployment. Since we have 22 deployments maybe it is better to ask only once and collect the matches for all the deployments.
-But we have to also keep in mind that if we have a match through the simpleQuery we won't go any further and we may loose some not perfect matches. So either
-to combine the queries together or call as many times as queries types (which also may be expensive process if we increase a number of the query types)
+Problem.1. (solved)
+Matching used to work per deployment; with 22 deployments it was up to 22 requests per query type. Now every query type is a single msearch
+with one search per deployment, so the totals and the per-deployment uniqueness are the same as before. A deployment with a match
+doesn't go any further (unless collecting from every step), the others continue with the next query type
 
-Problem.2.
+Problem.2. (reported)
 OneKey has duplicates and it's imposible (without additional keys/locations/...) to distinguish the rows. Even with the locations from OneKey we normally
 merge people with the same names anyway. So maybe there are two John's Smith's but not in our system. We should probably collect as many oneky's as
 possible in additional mysql table and later decide either to unmerge experts or ignore the issue.
+Such people are not matched but stored in kol__onekey_ambiguity table (or the dry-run report) with the OneKey IDs and the candidates.
 
 
 
-@todo add aliases/nicknames to every search step!
 @todo replaced names
 @todo introduce goroutines for eg. saving onekey to external db https://medium.com/@nikolay.bystritskiy/how-i-tried-to-do-things-asynchronously-in-golang-40e0c1a06a66
                 also remember (In Go, when the main function exits, the program stops as well as all goroutines!!!!): https://medium.com/@matryer/very-basic-concurrency-for-beginners-in-go-663e63c6ba07 In Go, when the main function exits, the program stops.
 */
 
 import (
+	"context"
 	"flag"
 	"fmt"
+	"log"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/tomekwlod/okpii/aliases"
+	"github.com/tomekwlod/okpii/matching"
 	modelsES "github.com/tomekwlod/okpii/models/es"
 	modelsMongodb "github.com/tomekwlod/okpii/models/mongodb"
 	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
 	"github.com/tomekwlod/okpii/tools"
-	strutils "github.com/tomekwlod/utils/strings"
 	_ "golang.org/x/net/html/charset"
 )
 
-const collectFromEveryStep = true
-
 type service struct {
-	es    modelsES.Repository
-	mysql modelsMysql.Repository
-	mongo modelsMongodb.Repository
+	es      modelsES.Repository
+	mysql   modelsMysql.Repository
+	mongo   modelsMongodb.Repository
+	matcher *matching.Pipeline
 	// logger  *log.Logger
 }
 
@@ -65,23 +69,128 @@ func main() {
 		"",
 		"Pass single OneKey to investigate it")
 
+	runFlag := flag.String(
+		"run",
+		"",
+		"Run ID the matches are stored under (default: current time). Results of an existing run are replaced")
+
+	resumeFlag := flag.String(
+		"resume",
+		"",
+		"Run ID to be continued from its last checkpoint")
+
+	dryRunFlag := flag.Bool(
+		"dry-run",
+		false,
+		"Write the matches to the -report file instead of MySQL")
+
+	diffFlag := flag.Bool(
+		"diff",
+		false,
+		"Compare the matches with the ones stored in kol__onekey and write the differences to the -report file (implies -dry-run)")
+
+	reportFlag := flag.String(
+		"report",
+		"",
+		"Report file for -dry-run or -diff; JSON Lines or CSV if it ends with .csv (default: log/matching-<runID>.jsonl)")
+
+	workersFlag := flag.Int(
+		"workers",
+		4,
+		"Number of the concurrent searches")
+
+	queueFlag := flag.Int(
+		"queue",
+		100,
+		"Size of the write queue; the searches wait if the queue is full")
+
+	configFlag := flag.String(
+		"config",
+		"",
+		"JSON file with the matching configuration (collectAll, strategies, disabled, maxResults)")
+
+	collectAllFlag := flag.String(
+		"collect-all",
+		"",
+		"Collect the matches from every step (true) or stop on the first step with a match (false)")
+
+	cityFlag := flag.String(
+		"city",
+		"",
+		"Use the OneKey city (true) to boost the candidates from the same city and to pick one of many in the risky strategies")
+
+	strategiesFlag := flag.String(
+		"strategies",
+		"",
+		"Comma separated list of the strategies to run in the given order (skip to run all of them)")
+
+	disableFlag := flag.String(
+		"disable",
+		"",
+		"Comma separated list of the strategies to skip")
+
+	maxFlag := flag.String(
+		"max",
+		"",
+		"Max number of the matches per strategy and deployment, eg. simple=2,short=1")
+
+	timeoutFlag := flag.Duration(
+		"timeout",
+		30*time.Second,
+		"Max time of all the searches of a single OneKey row")
+
+	explainFlag := flag.Bool(
+		"explain",
+		false,
+		"Print the queries, the hits with the ES explain and the post-filter decisions of every strategy for the -onekey as JSON; nothing is stored")
+
+	nicknamesFlag := flag.String(
+		"nicknames",
+		"mysql",
+		"Nickname dictionary used for the OneKey first names: mysql (the firstname table) or a JSON file")
+
+	runsFlag := flag.Bool(
+		"runs",
+		false,
+		"List the completed runs and exit")
+
 	// once done with the flags/arguments let's parse them
 	flag.Parse()
 
-	// grab deployments from an argument[1] - comma separated string
-	deployments, err := tools.Deployments(*didFlag)
-	if err != nil {
-		panic(err)
+	if *runsFlag {
+		mysqlClient, err := modelsMysql.MysqlClient()
+		if err != nil {
+			panic(err)
+		}
+		defer mysqlClient.Close()
+
+		if err = listRuns(mysqlClient); err != nil {
+			panic(err)
+		}
+
+		return
 	}
-	fmt.Printf("\n> Starting with: %v deployment(s)\n", deployments)
 
 	singleOK := *singleOKFlag
 	if singleOK != "" {
 		fmt.Printf("\n> Checking only one key: %s\n\n", singleOK)
 	}
 
+	cfg, err := loadConfig(*configFlag, *collectAllFlag, *cityFlag, *strategiesFlag, *disableFlag, *maxFlag)
+	if err != nil {
+		panic(err)
+	}
+
+	if *explainFlag && singleOK == "" {
+		panic("-explain needs -onekey")
+	}
+
+	dryRun := *dryRunFlag || *diffFlag
+	if dryRun && *resumeFlag != "" {
+		panic("-resume cannot be combined with -dry-run nor -diff")
+	}
+
 	t1 := time.Now()
-	var wg sync.WaitGroup
 
 	esClient, err := modelsES.ESClient()
 	if err != nil {
@@ -99,21 +208,160 @@ func main() {
 		panic(err)
 	}
 
+	nicknames, err := aliases.Load(*nicknamesFlag, mysqlClient)
+	if err != nil {
+		panic(err)
+	}
+	esClient.Nicknames = nicknames
+	fmt.Printf("\n> Nicknames: %d names, version %s\n", nicknames.Len(), nicknames.Version)
+
+	matcher, err := matching.Default().Configure(&matching.Env{
+		ES:        esClient,
+		Mongo:     mongoClient,
+		Nicknames: nicknames,
+		Logger:    log.New(os.Stdout, "", log.LstdFlags),
+	}, cfg)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("\n> Strategies: %s (collect from every step: %v, city-aware: %v)\n", strings.Join(matcher.Strategies(), ", "), matcher.CollectAll, matcher.CityAware)
+
 	s := &service{
-		es:    esClient,
-		mysql: mysqlClient,
-		mongo: mongoClient,
+		es:      esClient,
+		mysql:   mysqlClient,
+		mongo:   mongoClient,
+		matcher: matcher,
+	}
+
+	if *explainFlag {
+		deployments, err := tools.Deployments(*didFlag)
+		if err != nil {
+			panic(err)
+		}
+
+		dids := []int{}
+		for _, did := range deployments {
+			did, _ := strconv.Atoi(did)
+			dids = append(dids, did)
+		}
+
+		if err = explain(s, singleOK, dids, *timeoutFlag); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
+	// in dry-run mode nothing is stored in MySQL, the run included
+	var runStore modelsMysql.Repository = s.mysql
+	if dryRun {
+		runStore = nil
+	}
+
+	var run *tracker
+	if *resumeFlag != "" {
+		run, err = resumeRun(s.mysql, *resumeFlag)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		runID := *runFlag
+		if runID == "" {
+			runID = time.Now().Format("20060102150405")
+		} else if !dryRun {
+			deleted, err := s.mysql.ClearMatchResults(runID)
+			if err != nil {
+				panic(err)
+			}
+			fmt.Printf("\n> Removed %d results of the previous run %s\n", deleted, runID)
+
+			if _, err = s.mysql.ClearAmbiguities(runID); err != nil {
+				panic(err)
+			}
+		}
+
+		// grab deployments from an argument[1] - comma separated string
+		deployments, err := tools.Deployments(*didFlag)
+		if err != nil {
+			panic(err)
+		}
+
+		run, err = newRun(runStore, runID, deployments)
+		if err != nil {
+			panic(err)
+		}
+	}
+	runID := run.run.ID
+
+	deployments := []int{}
+	for _, did := range run.deployments() {
+		did, _ := strconv.Atoi(did)
+		deployments = append(deployments, did)
+	}
+	fmt.Printf("\n> Run %s with: %v deployment(s)\n", runID, deployments)
+
+	var out sink = &mysqlSink{mysql: s.mysql}
+	if dryRun {
+		report := *reportFlag
+		if report == "" {
+			report = "log/matching-" + runID + ".jsonl"
+		}
+
+		if *diffFlag {
+			out, err = newDiffSink(s.mysql, report)
+		} else {
+			out, err = newReportSink(report, reportHeader)
+		}
+		if err != nil {
+			panic(err)
+		}
+
+		fmt.Printf("\n> Dry-run, writing to: %s\n", report)
+	}
+
+	after := run.after(deployments)
+	if after != "" {
+		fmt.Printf("\n> Resuming after: %s\n", after)
 	}
 
+	// the first SIGINT/SIGTERM stops reading OneKey and lets the queued searches finish,
+	// the second one cancels the searches in progress
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stop := make(chan struct{})
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sig
+		fmt.Printf("\n> Interrupted, finishing the searches in progress (interrupt again to cancel them)\n")
+		close(stop)
+
+		<-sig
+		fmt.Printf("\n> Cancelling the searches in progress\n")
+		cancel()
+	}()
+
+	p := newPool(ctx, s, runID, out, run, *workersFlag, *queueFlag, *timeoutFlag)
+
 	// Getting the experts from MongoDB line-by-line
 	ch := make(chan map[string]string) // one line only
-	go s.mongo.Onekeys(ch)
+	go s.mongo.Onekeys(ch, after)
 
 	var i int
+	interrupted := false
+read:
 	for m := range ch {
+		select {
+		case <-stop:
+			interrupted = true
+			break read
+		default:
+		}
+
 		i++
 
-		fn, mn, ln := names(m)
+		fn, mn, ln := onekeyNames(m)
 
 		if singleOK != "" {
 			// to test only one person
@@ -125,39 +373,35 @@ func main() {
 			}
 		}
 
-		for _, did := range deployments {
-			did, _ := strconv.Atoi(did)
-			id, _ := strconv.Atoi(m["SRC_CUST_ID"])
-
-			if m["CNTRY"] == "" {
-				fmt.Printf("\nNo country detected. Cannot continue because currently matching is based on the countries! Data: `%v`\n", m)
-
-				continue
+		if m["CNTRY"] == "" {
+			fmt.Printf("\nNo country detected. Cannot continue because currently matching is based on the countries! Data: `%v`\n", m)
+		} else {
+			dids := []int{}
+			for _, did := range deployments {
+				if !run.done(did, m["SRC_CUST_ID"]) {
+					dids = append(dids, did)
+				}
 			}
 
-			result := s.findMatches(did, id, m["CUST_NAME"], m["CNTRY"], m["CITY"], fn, mn, ln)
-
-			for queryNumber, matches := range result {
-				for _, match := range matches {
+			if len(dids) > 0 {
+				// all the deployments are searched at once
+				p.add(job{row: m, dids: dids, fn: fn, mn: mn, ln: ln})
 
-					if match["id"] != nil {
-						kid64 := match["id"].(float64)
-						kid := int(kid64)
+				// the rows done by the previous attempt and the ones without a country aren't counted
+				run.run.Processed++
+			}
+		}
 
-						if kid > 0 {
-							fmt.Printf("{q%d}: [%s] %s %s %s {%s, %s}\t\t ====> \t [%d] (did:%d) %s, {%s, %s} npi: %v, ttid: %v\n",
-								queryNumber, m["SRC_CUST_ID"], fn, mn, ln, m["CITY"], m["CNTRY"],
-								kid, did, match["name"], match["city"], match["country"], match["npi"], match["ttid"],
-							)
+		if singleOK == "" && i%checkpointEvery == 0 {
+			// everything before the checkpoint has to be stored first
+			p.wait()
 
-							wg.Add(1)
-							go s.mysql.AddOnekeyToKOL(&wg, kid, did, m["SRC_CUST_ID"])
+			if err := out.flush(); err != nil {
+				panic(err)
+			}
 
-						} else {
-							fmt.Println("ID NOT VALID ", match["id"], kid)
-						}
-					}
-				}
+			if err := run.save(); err != nil {
+				panic(err)
 			}
 		}
 
@@ -169,64 +413,61 @@ func main() {
 		}
 	}
 
-	t2 := time.Now()
-
-	wg.Wait()
-	fmt.Printf("\nAll done in: %v \n", t2.Sub(t1))
-}
+	p.close()
 
-// func (s *service) findMatches(did int, id, custName, city, fn, mn, ln string) (queryNumber int, result []map[string]interface{}) {
-func (s *service) findMatches(did, id int, custName, country, city, fn, mn, ln string) (result map[int][]map[string]interface{}) {
-	// this cannot seat in the return definition because it will panic below [assignment to entry in nil map]
-	result = map[int][]map[string]interface{}{}
+	t2 := time.Now()
 
-	if strings.Replace(fn, " ", "", -1) == "" {
-		// if no FN we should just continue; it causes too much hassle
-		return
+	if err := out.close(); err != nil {
+		panic(err)
 	}
 
-	var midres []map[string]interface{}
-	var noq = 6 // number of queries
-	exclIDs := []string{strconv.Itoa(id)}
-
-	for i := 1; i <= noq; i++ {
-		midres = s.search(i, custName, fn, mn, ln, country, city, did, exclIDs) //deployment=XX
-
-		// before, it was a return when we had a match inside this for-loop
-		// I introduced another for-loop underneath to append all the results from every search step
-		// this may bring more matches but at the same time it is more risky
-		//
-		// the const collectFromEveryStep=false is to switch off this behaviour if needed
-
-		if collectFromEveryStep == false {
-			if len(midres) == 0 {
-				// no results here, continue with another search
-				continue
-			}
-
-			for _, row := range midres {
-				result[i] = append(result[i], row)
-			}
+	if err := p.report("log/matching-" + runID + "-failures.jsonl"); err != nil {
+		panic(err)
+	}
 
-			// a match -> return with the matches from one search only
-			return
+	switch {
+	case ctx.Err() != nil:
+		// the cancelled searches are not complete; the last checkpoint stays
+		fmt.Printf("\n> Cancelled. Continue with: -resume=%s\n", runID)
+	case interrupted:
+		if err := run.save(); err != nil {
+			panic(err)
 		}
-
-		// this can happen only if const collectFromEveryStep=true -> so it will collect the results from
-		// every single search step
-		for _, row := range midres {
-			exclIDs = append(exclIDs, strconv.FormatFloat(row["id"].(float64), 'f', 0, 64))
-
-			result[i] = append(result[i], row)
+		fmt.Printf("\n> Stopped. Continue with: -resume=%s\n", runID)
+	case len(p.failures) > 0:
+		// a completed run can't be resumed; the checkpoints are kept before the failed rows
+		if err := run.save(); err != nil {
+			panic(err)
+		}
+		fmt.Printf("\n> %d failure(s). Search the failed rows again with: -resume=%s\n", len(p.failures), runID)
+	default:
+		if err := run.finish(); err != nil {
+			panic(err)
 		}
 	}
 
-	return
+	fmt.Printf("\nAll done in: %v \n", t2.Sub(t1))
+}
+
+func (s *service) findMatches(ctx context.Context, dids []int, id int, custName, country, city, fn, mn, ln string) (*matching.Result, error) {
+	return s.matcher.Run(ctx, matching.Input{
+		CustName: custName,
+		Fn:       fn,
+		Mn:       mn,
+		Ln:       ln,
+		Country:  country,
+		City:     city,
+		Dids:     dids,
+		ExclIDs:  []string{strconv.Itoa(id)},
+	})
 }
 
-func names(m map[string]string) (fn, mn, ln string) {
+// onekeyNames returns the names of the OneKey row. They are already parsed by the import (no titles, the particles
+// in the last name) so they are used as they are; parsing them again could move another word of the first name.
+// MIDDLE_NAME is used by the extracts having it; otherwise a first name with a space or a dash is split
+func onekeyNames(m map[string]string) (fn, mn, ln string) {
 	fn = m["FIRST_NAME"]
-	mn = ""
+	mn = m["MIDDLE_NAME"]
 	ln = m["LAST_NAME"]
 
 	// if no MN but a space in FN then split
@@ -251,95 +492,3 @@ func names(m map[string]string) (fn, mn, ln string) {
 
 	return
 }
-
-func (s *service) search(option int, custName, fn, mn, ln, country, city string, did int, exclIDs []string) (result []map[string]interface{}) {
-	switch option {
-	case 1:
-		result = s.es.SimpleSearch(fn, mn, ln, country, city, did, exclIDs)
-
-		if len(result) > 2 {
-			// maybe not needed?
-			return nil
-		}
-
-		return result
-	case 2:
-		return s.es.ShortSearch(fn, mn, ln, country, city, did, exclIDs)
-	case 3:
-		r := s.es.NoMiddleNameSearch(fn, mn, ln, country, city, did, exclIDs)
-
-		// for security reason - double checking if the match is the only one in the DB
-		for _, row := range r {
-			total := s.mongo.CountOneKeyOcc(custName, strutils.FirstChar(fn), ln)
-
-			if total != 0 {
-				// fmt.Printf("[%s] %s %s %s {%s}\t\t ====> \t TOO MANY RESULTS FOR {q%v}!\n", id, fn, mn, ln, city, option)
-				continue
-			}
-
-			result = append(result, row)
-		}
-
-		return result
-	case 4:
-		r := s.es.OneMiddleNameSearch(fn, mn, ln, country, city, did, exclIDs)
-
-		unique := map[string]string{}
-		for _, row := range r {
-			// the unique doesn't need to be based on the full names
-			// ES matching is already doing the FN matching so here all we have to do is
-			// to check the middle name and fn1 to be sure it is unique for our needs
-			key := fmt.Sprintf("%s%s", strutils.FirstChar(row["fn"].(string)), row["mn"])
-			unique[key] = key
-		}
-		if len(unique) > 1 {
-			// if we have non unique matches in this already risky matching
-			// we should not continue
-
-			// @todo:
-			// Frank G
-			// Frank G       // these to will be ok but not these ones:
-
-			// Frank G
-			// Frank George  // this should also be ok I believe
-			return nil
-		}
-
-		// for security reason - double checking if the match is the only one in the DB
-		for _, row := range r {
-			total := s.mongo.CountOneKeyOcc(custName, fn, ln)
-
-			if total != 0 {
-				// fmt.Printf("[%s] %s %s %s {%s}\t\t ====> \t TOO MANY RESULTS FOR {q%v}!\n", id, fn, mn, ln, city, option)
-				continue
-			}
-
-			result = append(result, row)
-		}
-
-		return result
-	case 5:
-		r := s.es.OneMiddleNameSearch2(fn, mn, ln, country, city, did, exclIDs)
-
-		// for security reason - double checking if the match is the only one in the DB
-		for _, row := range r {
-			total := s.mongo.CountOneKeyOcc(custName, fn, ln)
-
-			if total != 0 {
-				// fmt.Printf("[%s] %s %s %s {%s}\t\t ====> \t TOO MANY RESULTS FOR {q%v}!\n", id, fn, mn, ln, city, option)
-				continue
-			}
-
-			result = append(result, row)
-		}
-
-		return result
-	case 6:
-		r := s.es.ThreeInitialsSearch(fn, mn, ln, country, city, did, exclIDs)
-
-		return r
-	default:
-		return s.es.TestSearch(fn, mn, ln, country, city, did, exclIDs)
-		return nil
-	}
-}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
+)
+
+// job is a single OneKey row to be matched within the deployments
+type job struct {
+	row        map[string]string
+	dids       []int
+	fn, mn, ln string
+}
+
+// outcome is what the worker found for the job
+type outcome struct {
+	job
+
+	// did -> matches
+	results     map[int][]*modelsMysql.MatchResult
+	ambiguities []*modelsMysql.Ambiguity
+	err         error
+}
+
+// failure is a single entry of the run's failure report
+type failure struct {
+	Onekey string `json:"onekey"`
+	Did    int    `json:"did"`
+	Stage  string `json:"stage"`
+	Error  string `json:"error"`
+}
+
+// pool runs the searches concurrently with a limited number of workers. The results are passed
+// through a bounded queue to a single writer; if the writer is slow the workers (and the reader) wait
+type pool struct {
+	s     *service
+	runID string
+	out   sink
+	run   *tracker
+
+	// ctx cancels the searches in progress, timeout limits the searches of a single job
+	ctx     context.Context
+	timeout time.Duration
+
+	jobs   chan job
+	writes chan outcome
+
+	pending sync.WaitGroup // jobs not written yet
+	workers sync.WaitGroup
+	written chan struct{}
+
+	failures []failure
+}
+
+func newPool(ctx context.Context, s *service, runID string, out sink, run *tracker, workers, queue int, timeout time.Duration) *pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queue < 1 {
+		queue = 1
+	}
+
+	p := &pool{
+		s:       s,
+		runID:   runID,
+		out:     out,
+		run:     run,
+		ctx:     ctx,
+		timeout: timeout,
+		jobs:    make(chan job, workers),
+		writes:  make(chan outcome, queue),
+		written: make(chan struct{}),
+	}
+
+	for i := 0; i < workers; i++ {
+		p.workers.Add(1)
+		go p.work()
+	}
+
+	go p.write()
+
+	return p
+}
+
+// add queues the job; it blocks if all the workers are busy
+func (p *pool) add(j job) {
+	p.pending.Add(1)
+	p.jobs <- j
+}
+
+// wait waits until everything queued so far has been written
+func (p *pool) wait() {
+	p.pending.Wait()
+}
+
+// close waits for all the jobs and stops the workers and the writer
+func (p *pool) close() {
+	close(p.jobs)
+	p.workers.Wait()
+
+	close(p.writes)
+	<-p.written
+}
+
+func (p *pool) work() {
+	defer p.workers.Done()
+
+	for j := range p.jobs {
+		id, _ := strconv.Atoi(j.row["SRC_CUST_ID"])
+
+		ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
+		result, err := p.s.findMatches(ctx, j.dids, id, j.row["CUST_NAME"], j.row["CNTRY"], j.row["CITY"], j.fn, j.mn, j.ln)
+		cancel()
+		if err != nil {
+			p.writes <- outcome{job: j, err: err}
+
+			continue
+		}
+
+		results := map[int][]*modelsMysql.MatchResult{}
+		for _, match := range result.Matches {
+			confidence := ""
+			if match.LowConfidence {
+				confidence = " LOW"
+			}
+
+			fmt.Printf("{%s %.2f%s}: [%s] %s %s %s {%s, %s}\t\t ====> \t [%d] (did:%d) %s, {%s, %s} npi: %v, ttid: %v\n",
+				match.Strategy, match.Score, confidence, j.row["SRC_CUST_ID"], j.fn, j.mn, j.ln, j.row["CITY"], j.row["CNTRY"],
+				match.ID, match.Did, match.Expert.Name, match.Expert.City, match.Expert.Country, match.Expert.NPI, match.Expert.TTID,
+			)
+
+			results[match.Did] = append(results[match.Did], &modelsMysql.MatchResult{
+				RunID:         p.runID,
+				Onekey:        j.row["SRC_CUST_ID"],
+				KID:           match.ID,
+				Did:           match.Did,
+				Strategy:      match.Strategy,
+				Score:         match.Score,
+				LowConfidence: match.LowConfidence,
+				InputFn:       j.fn,
+				InputMn:       j.mn,
+				InputLn:       j.ln,
+				MatchedFn:     match.Expert.Fn,
+				MatchedMn:     match.Expert.Mn,
+				MatchedLn:     match.Expert.Ln,
+			})
+		}
+
+		ambiguities := []*modelsMysql.Ambiguity{}
+		for _, a := range result.Ambiguities {
+			fmt.Printf("{%s}: [%s] %s %s %s\t\t ====> \t ambiguous in OneKey (did:%d) %s: %v, candidates: %v\n",
+				a.Strategy, j.row["SRC_CUST_ID"], j.fn, j.mn, j.ln, a.Did, a.Signature, a.Onekeys, a.KIDs,
+			)
+
+			ambiguities = append(ambiguities, &modelsMysql.Ambiguity{
+				RunID:     p.runID,
+				Onekey:    j.row["SRC_CUST_ID"],
+				Did:       a.Did,
+				Strategy:  a.Strategy,
+				Signature: a.Signature,
+				Onekeys:   a.Onekeys,
+				KIDs:      a.KIDs,
+			})
+		}
+
+		p.writes <- outcome{job: j, results: results, ambiguities: ambiguities}
+	}
+}
+
+// write is the only goroutine touching the sink and the run's counters
+func (p *pool) write() {
+	defer close(p.written)
+
+	for o := range p.writes {
+		onekey := o.row["SRC_CUST_ID"]
+
+		for _, did := range o.dids {
+			if o.err != nil {
+				p.fail(onekey, did, "search", o.err)
+			} else if err := p.out.write(onekey, did, o.results[did]); err != nil {
+				p.fail(onekey, did, "write", err)
+			} else {
+				p.run.run.Matched += len(o.results[did])
+				p.run.processed(did, onekey)
+			}
+		}
+
+		for _, a := range o.ambiguities {
+			if err := p.out.ambiguous(a); err != nil {
+				p.fail(onekey, a.Did, "write", err)
+			}
+		}
+
+		p.pending.Done()
+	}
+}
+
+func (p *pool) fail(onekey string, did int, stage string, err error) {
+	fmt.Printf("\nMatching [%s] (did:%d) failed at %s: %v\n", onekey, did, stage, err)
+
+	p.run.fail(did, onekey)
+
+	p.failures = append(p.failures, failure{Onekey: onekey, Did: did, Stage: stage, Error: err.Error()})
+}
+
+// report writes the failures (if any) as JSON Lines; call it after close
+func (p *pool) report(filename string) error {
+	if len(p.failures) == 0 {
+		return nil
+	}
+
+	f, err := createFile(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, failure := range p.failures {
+		if err = enc.Encode(failure); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n%d failure(s) written to: %s\n", len(p.failures), filename)
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
+)
+
+// how often (in OneKey rows) the progress is stored
+const checkpointEvery = 500
+
+// tracker keeps the run details and the last processed OneKey per deployment
+type tracker struct {
+	mysql modelsMysql.Repository
+	run   *modelsMysql.Run
+
+	// resumed are the checkpoints the run started with, checkpoints are the current ones and
+	// saved the ones stored last
+	resumed     map[int]string
+	checkpoints map[int]string
+	saved       map[int]string
+
+	// failed is the lowest failed OneKey per deployment; the checkpoint never moves past it,
+	// so the failed rows are searched again by -resume
+	failed map[int]string
+}
+
+// newRun starts a fresh run. An existing run with the same ID is started over.
+// With no mysql (dry-run) nothing is stored
+func newRun(mysql modelsMysql.Repository, runID string, deployments []string) (*tracker, error) {
+	t := &tracker{
+		mysql: mysql,
+		run: &modelsMysql.Run{
+			ID:          runID,
+			Status:      modelsMysql.RunRunning,
+			Deployments: strings.Join(deployments, ","),
+			StartedAt:   time.Now(),
+		},
+		resumed:     map[int]string{},
+		checkpoints: map[int]string{},
+		saved:       map[int]string{},
+		failed:      map[int]string{},
+	}
+
+	return t, t.save()
+}
+
+// resumeRun continues the run from its checkpoints
+func resumeRun(mysql modelsMysql.Repository, runID string) (*tracker, error) {
+	run, err := mysql.Run(runID)
+	if err != nil {
+		return nil, fmt.Errorf("Run %s couldn't be loaded: %v", runID, err)
+	}
+
+	if run.Status == modelsMysql.RunCompleted {
+		return nil, fmt.Errorf("Run %s is already completed", runID)
+	}
+
+	checkpoints, err := mysql.Checkpoints(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	run.Status = modelsMysql.RunRunning
+	run.FinishedAt = nil
+
+	t := &tracker{mysql: mysql, run: run, resumed: checkpoints, checkpoints: map[int]string{}, saved: map[int]string{}, failed: map[int]string{}}
+	for did, onekey := range checkpoints {
+		t.checkpoints[did] = onekey
+		t.saved[did] = onekey
+	}
+
+	return t, mysql.SaveRun(t.run)
+}
+
+// deployments returns the deployments of the run
+func (t *tracker) deployments() []string {
+	return strings.Split(t.run.Deployments, ",")
+}
+
+// after returns the OneKey ID the stream can start after; the lowest checkpoint of all the deployments
+func (t *tracker) after(deployments []int) (after string) {
+	for i, did := range deployments {
+		checkpoint, ok := t.resumed[did]
+		if !ok {
+			// at least one deployment has to start from the beginning
+			return ""
+		}
+
+		if i == 0 || checkpoint < after {
+			after = checkpoint
+		}
+	}
+
+	return
+}
+
+// done tells if the OneKey was already processed for the deployment in the previous attempt of the run
+func (t *tracker) done(did int, onekey string) bool {
+	checkpoint, ok := t.resumed[did]
+
+	return ok && onekey <= checkpoint
+}
+
+// processed moves the deployment's checkpoint forward; the rows can be processed out of order.
+// After a failure the checkpoint stays below the failed row
+func (t *tracker) processed(did int, onekey string) {
+	if failed, ok := t.failed[did]; ok && onekey >= failed {
+		return
+	}
+
+	if onekey > t.checkpoints[did] {
+		t.checkpoints[did] = onekey
+	}
+}
+
+// fail keeps the deployment's checkpoint below the failed row. The rows after the last saved
+// checkpoint may have been processed out of order, so a checkpoint past the row goes back to it
+func (t *tracker) fail(did int, onekey string) {
+	if failed, ok := t.failed[did]; ok && failed <= onekey {
+		return
+	}
+
+	t.failed[did] = onekey
+
+	if t.checkpoints[did] >= onekey {
+		t.checkpoints[did] = t.saved[did]
+	}
+}
+
+// save stores the checkpoints and the counters
+func (t *tracker) save() error {
+	if t.mysql == nil {
+		return nil
+	}
+
+	for did, onekey := range t.checkpoints {
+		if err := t.mysql.SaveCheckpoint(t.run.ID, did, onekey); err != nil {
+			return err
+		}
+
+		t.saved[did] = onekey
+	}
+
+	return t.mysql.SaveRun(t.run)
+}
+
+// finish marks the run as completed
+func (t *tracker) finish() error {
+	now := time.Now()
+
+	t.run.Status = modelsMysql.RunCompleted
+	t.run.FinishedAt = &now
+
+	return t.save()
+}
+
+func listRuns(mysql modelsMysql.Repository) error {
+	runs, err := mysql.Runs(modelsMysql.RunCompleted)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%-16s %-20s %10s %10s %15s  %s\n", "RUN", "STARTED", "PROCESSED", "MATCHED", "DURATION", "DEPLOYMENTS")
+	for _, r := range runs {
+		fmt.Printf("%-16s %-20s %10d %10d %15v  %s\n",
+			r.ID, r.StartedAt.Format("2006-01-02 15:04:05"), r.Processed, r.Matched, r.Duration().Round(time.Second), r.Deployments)
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
+)
+
+// sink receives the matches; it is either MySQL or a report file
+type sink interface {
+	// write is called once per OneKey row and deployment, also when nothing has been matched
+	write(onekey string, did int, results []*modelsMysql.MatchResult) error
+
+	// ambiguous is called for every OneKey person who couldn't be matched because of the OneKey duplicates
+	ambiguous(a *modelsMysql.Ambiguity) error
+
+	// flush makes sure everything written so far is stored
+	flush() error
+
+	close() error
+}
+
+// mysqlSink stores the matches in kol__onekey and kol__onekey_match tables
+type mysqlSink struct {
+	mysql modelsMysql.Repository
+}
+
+func (s *mysqlSink) write(onekey string, did int, results []*modelsMysql.MatchResult) error {
+	for _, r := range results {
+		if _, err := s.mysql.AddOnekeyToKOL(r.KID, r.Did, r.Onekey); err != nil {
+			return fmt.Errorf("Couldn't link [%s] -> [%d]: %v", r.Onekey, r.KID, err)
+		}
+
+		if err := s.mysql.SaveMatchResult(r); err != nil {
+			return fmt.Errorf("Couldn't save the match [%s] -> [%d]: %v", r.Onekey, r.KID, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *mysqlSink) ambiguous(a *modelsMysql.Ambiguity) error {
+	if err := s.mysql.SaveAmbiguity(a); err != nil {
+		return fmt.Errorf("Couldn't save the ambiguity [%s] (did:%d): %v", a.Onekey, a.Did, err)
+	}
+
+	return nil
+}
+
+func (s *mysqlSink) flush() error {
+	return nil
+}
+
+func (s *mysqlSink) close() error {
+	return nil
+}
+
+// reportSink writes the matches to a JSON Lines or (if the file ends with .csv) a CSV file.
+// The ambiguities go to a separate <report>-ambiguities.jsonl file on close
+type reportSink struct {
+	file *os.File
+	buf  *bufio.Writer
+	csv  *csv.Writer
+	json *json.Encoder
+
+	filename    string
+	ambiguities []*modelsMysql.Ambiguity
+}
+
+var reportHeader = []string{
+	"run_id", "onekey", "did", "kid", "strategy", "score", "low_confidence",
+	"input_fn", "input_mn", "input_ln", "matched_fn", "matched_mn", "matched_ln",
+}
+
+func newReportSink(filename string, header []string) (*reportSink, error) {
+	f, err := createFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &reportSink{file: f, buf: bufio.NewWriter(f), filename: filename}
+
+	if strings.ToLower(filepath.Ext(filename)) == ".csv" {
+		s.csv = csv.NewWriter(s.buf)
+
+		if err = s.csv.Write(header); err != nil {
+			return nil, err
+		}
+	} else {
+		s.json = json.NewEncoder(s.buf)
+	}
+
+	return s, nil
+}
+
+func (s *reportSink) write(onekey string, did int, results []*modelsMysql.MatchResult) error {
+	for _, r := range results {
+		if s.json != nil {
+			if err := s.json.Encode(r); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		err := s.csv.Write([]string{
+			r.RunID, r.Onekey, strconv.Itoa(r.Did), strconv.Itoa(r.KID), r.Strategy, strconv.FormatFloat(r.Score, 'f', 4, 64), strconv.FormatBool(r.LowConfidence),
+			r.InputFn, r.InputMn, r.InputLn, r.MatchedFn, r.MatchedMn, r.MatchedLn,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *reportSink) ambiguous(a *modelsMysql.Ambiguity) error {
+	s.ambiguities = append(s.ambiguities, a)
+
+	return nil
+}
+
+func (s *reportSink) flush() error {
+	if s.csv != nil {
+		s.csv.Flush()
+
+		if err := s.csv.Error(); err != nil {
+			return err
+		}
+	}
+
+	return s.buf.Flush()
+}
+
+func (s *reportSink) close() error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+
+	if len(s.ambiguities) == 0 {
+		return nil
+	}
+
+	filename := strings.TrimSuffix(s.filename, filepath.Ext(s.filename)) + "-ambiguities.jsonl"
+
+	f, err := createFile(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, a := range s.ambiguities {
+		if err = enc.Encode(a); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n%d ambiguities written to: %s\n", len(s.ambiguities), filename)
+
+	return nil
+}
+
+// createFile creates the file together with its directory (eg. log/, missing on a fresh checkout)
+func createFile(filename string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return nil, err
+	}
+
+	return os.Create(filename)
+}
@@ -11,6 +11,8 @@ import (
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/gorilla/context"
 	"github.com/justinas/alice"
+	"github.com/tomekwlod/okpii/aliases"
+	"github.com/tomekwlod/okpii/matching"
 	"github.com/tomekwlod/okpii/models"
 	modelsES "github.com/tomekwlod/okpii/models/es"
 	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
@@ -19,10 +21,12 @@ import (
 
 // service struct to hold the db and the logger
 type service struct {
-	es     modelsES.Repository
-	mysql  modelsMysql.Repository
-	logger *ml.Logger
-	telbot *tgbotapi.BotAPI
+	es        modelsES.Repository
+	mysql     modelsMysql.Repository
+	matcher   *matching.Pipeline
+	nicknames *aliases.Dictionary
+	logger    *ml.Logger
+	telbot    *tgbotapi.BotAPI
 }
 
 func main() {
@@ -59,11 +63,40 @@ func main() {
 	}
 	bot.Debug = botDebug
 
+	// search query one-by-one collecting the results from all of them (unless configured otherwise)
+	cfg := matching.DefaultConfig()
+	if os.Getenv("MATCHING_CONFIG") != "" {
+		cfg, err = matching.LoadConfig(os.Getenv("MATCHING_CONFIG"))
+		if err != nil {
+			log.Fatalln("Failed to load the matching config", err)
+		}
+	}
+
+	// NICKNAMES is a JSON file with the nickname dictionary; the firstname table is used by default.
+	// The dictionary is loaded once, restart the service after changing it
+	nicknames, err := aliases.Load(os.Getenv("NICKNAMES"), mysqlClient)
+	if err != nil {
+		log.Fatalln("Failed to load the nicknames", err)
+	}
+	esClient.Nicknames = nicknames
+
+	// no MongoDB here so the OneKey uniqueness checks are skipped
+	matcher, err := matching.Default().Configure(&matching.Env{
+		ES:        esClient,
+		Nicknames: nicknames,
+		Logger:    l,
+	}, cfg)
+	if err != nil {
+		log.Fatalln("Failed to build the matching pipeline", err)
+	}
+
 	s := &service{
-		es:     esClient,
-		mysql:  mysqlClient,
-		logger: l,
-		telbot: bot,
+		es:        esClient,
+		mysql:     mysqlClient,
+		matcher:   matcher,
+		nicknames: nicknames,
+		logger:    l,
+		telbot:    bot,
 	}
 
 	commonHandlers := alice.New(
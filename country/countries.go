@@ -0,0 +1,255 @@
+package country
+
+// countries is the ISO 3166-1 table. The names are the ones used by SciIQ (location.country_name),
+// the other names contain the ISO names and the local ones
+var countries = []Country{
+	{"AD", "AND", "Andorra", []string{"Principality of Andorra"}},
+	{"AE", "ARE", "United Arab Emirates", []string{}},
+	{"AF", "AFG", "Afghanistan", []string{"Islamic Republic of Afghanistan"}},
+	{"AG", "ATG", "Antigua and Barbuda", []string{}},
+	{"AI", "AIA", "Anguilla", []string{}},
+	{"AL", "ALB", "Albania", []string{"Republic of Albania"}},
+	{"AM", "ARM", "Armenia", []string{"Republic of Armenia"}},
+	{"AO", "AGO", "Angola", []string{"Republic of Angola"}},
+	{"AQ", "ATA", "Antarctica", []string{}},
+	{"AR", "ARG", "Argentina", []string{"Argentine Republic"}},
+	{"AS", "ASM", "American Samoa", []string{}},
+	{"AT", "AUT", "Austria", []string{"Republic of Austria", "Österreich"}},
+	{"AU", "AUS", "Australia", []string{}},
+	{"AW", "ABW", "Aruba", []string{}},
+	{"AX", "ALA", "Åland Islands", []string{}},
+	{"AZ", "AZE", "Azerbaijan", []string{"Republic of Azerbaijan"}},
+	{"BA", "BIH", "Bosnia and Herzegovina", []string{"Republic of Bosnia and Herzegovina"}},
+	{"BB", "BRB", "Barbados", []string{}},
+	{"BD", "BGD", "Bangladesh", []string{"People's Republic of Bangladesh"}},
+	{"BE", "BEL", "Belgium", []string{"Kingdom of Belgium", "België", "Belgique", "Belgien"}},
+	{"BF", "BFA", "Burkina Faso", []string{}},
+	{"BG", "BGR", "Bulgaria", []string{"Republic of Bulgaria", "България"}},
+	{"BH", "BHR", "Bahrain", []string{"Kingdom of Bahrain"}},
+	{"BI", "BDI", "Burundi", []string{"Republic of Burundi"}},
+	{"BJ", "BEN", "Benin", []string{"Republic of Benin"}},
+	{"BL", "BLM", "Saint Barthélemy", []string{}},
+	{"BM", "BMU", "Bermuda", []string{}},
+	{"BN", "BRN", "Brunei", []string{"Brunei Darussalam"}},
+	{"BO", "BOL", "Bolivia", []string{"Bolivia, Plurinational State of", "Plurinational State of Bolivia"}},
+	{"BQ", "BES", "Bonaire, Sint Eustatius and Saba", []string{}},
+	{"BR", "BRA", "Brazil", []string{"Federative Republic of Brazil", "Brasil"}},
+	{"BS", "BHS", "Bahamas", []string{"Commonwealth of the Bahamas"}},
+	{"BT", "BTN", "Bhutan", []string{"Kingdom of Bhutan"}},
+	{"BV", "BVT", "Bouvet Island", []string{}},
+	{"BW", "BWA", "Botswana", []string{"Republic of Botswana"}},
+	{"BY", "BLR", "Belarus", []string{"Republic of Belarus"}},
+	{"BZ", "BLZ", "Belize", []string{}},
+	{"CA", "CAN", "Canada", []string{}},
+	{"CC", "CCK", "Cocos (Keeling) Islands", []string{}},
+	{"CD", "COD", "Democratic Republic of the Congo", []string{"Congo, The Democratic Republic of the"}},
+	{"CF", "CAF", "Central African Republic", []string{}},
+	{"CG", "COG", "Republic of the Congo", []string{"Congo"}},
+	{"CH", "CHE", "Switzerland", []string{"Swiss Confederation", "Schweiz", "Suisse", "Svizzera", "Svizra", "Helvetia"}},
+	{"CI", "CIV", "Ivory Coast", []string{"Côte d'Ivoire", "Republic of Côte d'Ivoire"}},
+	{"CK", "COK", "Cook Islands", []string{}},
+	{"CL", "CHL", "Chile", []string{"Republic of Chile"}},
+	{"CM", "CMR", "Cameroon", []string{"Republic of Cameroon"}},
+	{"CN", "CHN", "China", []string{"People's Republic of China", "中国", "Zhongguo"}},
+	{"CO", "COL", "Colombia", []string{"Republic of Colombia"}},
+	{"CR", "CRI", "Costa Rica", []string{"Republic of Costa Rica"}},
+	{"CU", "CUB", "Cuba", []string{"Republic of Cuba"}},
+	{"CV", "CPV", "Cape Verde", []string{"Cabo Verde", "Republic of Cabo Verde"}},
+	{"CW", "CUW", "Curaçao", []string{}},
+	{"CX", "CXR", "Christmas Island", []string{}},
+	{"CY", "CYP", "Cyprus", []string{"Republic of Cyprus", "Κύπρος", "Kıbrıs"}},
+	{"CZ", "CZE", "Czech Republic", []string{"Czechia", "Česko", "Česká republika"}},
+	{"DE", "DEU", "Germany", []string{"Federal Republic of Germany", "Deutschland", "Bundesrepublik Deutschland"}},
+	{"DJ", "DJI", "Djibouti", []string{"Republic of Djibouti"}},
+	{"DK", "DNK", "Denmark", []string{"Kingdom of Denmark", "Danmark"}},
+	{"DM", "DMA", "Dominica", []string{"Commonwealth of Dominica"}},
+	{"DO", "DOM", "Dominican Republic", []string{}},
+	{"DZ", "DZA", "Algeria", []string{"People's Democratic Republic of Algeria", "Algérie"}},
+	{"EC", "ECU", "Ecuador", []string{"Republic of Ecuador"}},
+	{"EE", "EST", "Estonia", []string{"Republic of Estonia", "Eesti"}},
+	{"EG", "EGY", "Egypt", []string{"Arab Republic of Egypt", "مصر"}},
+	{"EH", "ESH", "Western Sahara", []string{}},
+	{"ER", "ERI", "Eritrea", []string{"the State of Eritrea"}},
+	{"ES", "ESP", "Spain", []string{"Kingdom of Spain", "España", "Espana"}},
+	{"ET", "ETH", "Ethiopia", []string{"Federal Democratic Republic of Ethiopia"}},
+	{"FI", "FIN", "Finland", []string{"Republic of Finland", "Suomi"}},
+	{"FJ", "FJI", "Fiji", []string{"Republic of Fiji"}},
+	{"FK", "FLK", "Falkland Islands (Malvinas)", []string{}},
+	{"FM", "FSM", "Micronesia", []string{"Micronesia, Federated States of", "Federated States of Micronesia"}},
+	{"FO", "FRO", "Faroe Islands", []string{"Føroyar"}},
+	{"FR", "FRA", "France", []string{"French Republic", "République française"}},
+	{"GA", "GAB", "Gabon", []string{"Gabonese Republic"}},
+	{"GB", "GBR", "United Kingdom", []string{"United Kingdom of Great Britain and Northern Ireland", "Great Britain", "UK", "England", "Scotland", "Wales", "Northern Ireland"}},
+	{"GD", "GRD", "Grenada", []string{}},
+	{"GE", "GEO", "Georgia", []string{}},
+	{"GF", "GUF", "French Guiana", []string{"Guyane"}},
+	{"GG", "GGY", "Guernsey", []string{}},
+	{"GH", "GHA", "Ghana", []string{"Republic of Ghana"}},
+	{"GI", "GIB", "Gibraltar", []string{}},
+	{"GL", "GRL", "Greenland", []string{"Kalaallit Nunaat", "Grønland"}},
+	{"GM", "GMB", "Gambia", []string{"Republic of the Gambia"}},
+	{"GN", "GIN", "Guinea", []string{"Republic of Guinea"}},
+	{"GP", "GLP", "Guadeloupe", []string{}},
+	{"GQ", "GNQ", "Equatorial Guinea", []string{"Republic of Equatorial Guinea"}},
+	{"GR", "GRC", "Greece", []string{"Hellenic Republic", "Ελλάδα", "Hellas", "Ellada"}},
+	{"GS", "SGS", "South Georgia and the South Sandwich Islands", []string{}},
+	{"GT", "GTM", "Guatemala", []string{"Republic of Guatemala"}},
+	{"GU", "GUM", "Guam", []string{}},
+	{"GW", "GNB", "Guinea-Bissau", []string{"Republic of Guinea-Bissau"}},
+	{"GY", "GUY", "Guyana", []string{"Republic of Guyana"}},
+	{"HK", "HKG", "Hong Kong", []string{"Hong Kong Special Administrative Region of China"}},
+	{"HM", "HMD", "Heard Island and McDonald Islands", []string{}},
+	{"HN", "HND", "Honduras", []string{"Republic of Honduras"}},
+	{"HR", "HRV", "Croatia", []string{"Republic of Croatia", "Hrvatska"}},
+	{"HT", "HTI", "Haiti", []string{"Republic of Haiti"}},
+	{"HU", "HUN", "Hungary", []string{"Magyarország"}},
+	{"ID", "IDN", "Indonesia", []string{"Republic of Indonesia"}},
+	{"IE", "IRL", "Ireland", []string{"Éire", "Eire"}},
+	{"IL", "ISR", "Israel", []string{"State of Israel", "ישראל"}},
+	{"IM", "IMN", "Isle of Man", []string{}},
+	{"IN", "IND", "India", []string{"Republic of India", "Bharat", "भारत"}},
+	{"IO", "IOT", "British Indian Ocean Territory", []string{}},
+	{"IQ", "IRQ", "Iraq", []string{"Republic of Iraq"}},
+	{"IR", "IRN", "Iran", []string{"Iran, Islamic Republic of", "Islamic Republic of Iran"}},
+	{"IS", "ISL", "Iceland", []string{"Republic of Iceland", "Ísland"}},
+	{"IT", "ITA", "Italy", []string{"Italian Republic", "Italia"}},
+	{"JE", "JEY", "Jersey", []string{}},
+	{"JM", "JAM", "Jamaica", []string{}},
+	{"JO", "JOR", "Jordan", []string{"Hashemite Kingdom of Jordan"}},
+	{"JP", "JPN", "Japan", []string{"日本", "Nippon"}},
+	{"KE", "KEN", "Kenya", []string{"Republic of Kenya"}},
+	{"KG", "KGZ", "Kyrgyzstan", []string{"Kyrgyz Republic"}},
+	{"KH", "KHM", "Cambodia", []string{"Kingdom of Cambodia"}},
+	{"KI", "KIR", "Kiribati", []string{"Republic of Kiribati"}},
+	{"KM", "COM", "Comoros", []string{"Union of the Comoros"}},
+	{"KN", "KNA", "Saint Kitts and Nevis", []string{}},
+	{"KP", "PRK", "North Korea", []string{"Korea, Democratic People's Republic of", "Democratic People's Republic of Korea"}},
+	{"KR", "KOR", "South Korea", []string{"Korea, Republic of", "대한민국", "Korea"}},
+	{"KW", "KWT", "Kuwait", []string{"State of Kuwait"}},
+	{"KY", "CYM", "Cayman Islands", []string{}},
+	{"KZ", "KAZ", "Kazakhstan", []string{"Republic of Kazakhstan"}},
+	{"LA", "LAO", "Laos", []string{"Lao People's Democratic Republic"}},
+	{"LB", "LBN", "Lebanon", []string{"Lebanese Republic"}},
+	{"LC", "LCA", "Saint Lucia", []string{}},
+	{"LI", "LIE", "Liechtenstein", []string{"Principality of Liechtenstein"}},
+	{"LK", "LKA", "Sri Lanka", []string{"Democratic Socialist Republic of Sri Lanka"}},
+	{"LR", "LBR", "Liberia", []string{"Republic of Liberia"}},
+	{"LS", "LSO", "Lesotho", []string{"Kingdom of Lesotho"}},
+	{"LT", "LTU", "Lithuania", []string{"Republic of Lithuania", "Lietuva"}},
+	{"LU", "LUX", "Luxembourg", []string{"Grand Duchy of Luxembourg", "Lëtzebuerg", "Luxemburg"}},
+	{"LV", "LVA", "Latvia", []string{"Republic of Latvia", "Latvija"}},
+	{"LY", "LBY", "Libya", []string{}},
+	{"MA", "MAR", "Morocco", []string{"Kingdom of Morocco", "المغرب", "Maroc"}},
+	{"MC", "MCO", "Monaco", []string{"Principality of Monaco"}},
+	{"MD", "MDA", "Moldova", []string{"Moldova, Republic of", "Republic of Moldova"}},
+	{"ME", "MNE", "Montenegro", []string{}},
+	{"MF", "MAF", "Saint Martin (French part)", []string{}},
+	{"MG", "MDG", "Madagascar", []string{"Republic of Madagascar"}},
+	{"MH", "MHL", "Marshall Islands", []string{"Republic of the Marshall Islands"}},
+	{"MK", "MKD", "North Macedonia", []string{"Republic of North Macedonia", "Macedonia", "Republic of Macedonia", "FYROM", "Former Yugoslav Republic of Macedonia", "Северна Македонија", "Македонија"}},
+	{"ML", "MLI", "Mali", []string{"Republic of Mali"}},
+	{"MM", "MMR", "Myanmar", []string{"Republic of Myanmar"}},
+	{"MN", "MNG", "Mongolia", []string{}},
+	{"MO", "MAC", "Macao", []string{"Macao Special Administrative Region of China"}},
+	{"MP", "MNP", "Northern Mariana Islands", []string{"Commonwealth of the Northern Mariana Islands"}},
+	{"MQ", "MTQ", "Martinique", []string{}},
+	{"MR", "MRT", "Mauritania", []string{"Islamic Republic of Mauritania"}},
+	{"MS", "MSR", "Montserrat", []string{}},
+	{"MT", "MLT", "Malta", []string{"Republic of Malta"}},
+	{"MU", "MUS", "Mauritius", []string{"Republic of Mauritius"}},
+	{"MV", "MDV", "Maldives", []string{"Republic of Maldives"}},
+	{"MW", "MWI", "Malawi", []string{"Republic of Malawi"}},
+	{"MX", "MEX", "Mexico", []string{"United Mexican States", "México"}},
+	{"MY", "MYS", "Malaysia", []string{}},
+	{"MZ", "MOZ", "Mozambique", []string{"Republic of Mozambique"}},
+	{"NA", "NAM", "Namibia", []string{"Republic of Namibia"}},
+	{"NC", "NCL", "New Caledonia", []string{"Nouvelle-Calédonie"}},
+	{"NE", "NER", "Niger", []string{"Republic of the Niger"}},
+	{"NF", "NFK", "Norfolk Island", []string{}},
+	{"NG", "NGA", "Nigeria", []string{"Federal Republic of Nigeria"}},
+	{"NI", "NIC", "Nicaragua", []string{"Republic of Nicaragua"}},
+	{"NL", "NLD", "Netherlands", []string{"Kingdom of the Netherlands", "Nederland", "Holland", "The Netherlands"}},
+	{"NO", "NOR", "Norway", []string{"Kingdom of Norway", "Norge", "Noreg"}},
+	{"NP", "NPL", "Nepal", []string{"Federal Democratic Republic of Nepal"}},
+	{"NR", "NRU", "Nauru", []string{"Republic of Nauru"}},
+	{"NU", "NIU", "Niue", []string{}},
+	{"NZ", "NZL", "New Zealand", []string{}},
+	{"OM", "OMN", "Oman", []string{"Sultanate of Oman"}},
+	{"PA", "PAN", "Panama", []string{"Republic of Panama"}},
+	{"PE", "PER", "Peru", []string{"Republic of Peru", "Perú"}},
+	{"PF", "PYF", "French Polynesia", []string{"Polynésie française"}},
+	{"PG", "PNG", "Papua New Guinea", []string{"Independent State of Papua New Guinea"}},
+	{"PH", "PHL", "Philippines", []string{"Republic of the Philippines"}},
+	{"PK", "PAK", "Pakistan", []string{"Islamic Republic of Pakistan"}},
+	{"PL", "POL", "Poland", []string{"Republic of Poland", "Polska", "Rzeczpospolita Polska"}},
+	{"PM", "SPM", "Saint Pierre and Miquelon", []string{"Saint-Pierre-et-Miquelon"}},
+	{"PN", "PCN", "Pitcairn", []string{}},
+	{"PR", "PRI", "Puerto Rico", []string{}},
+	{"PS", "PSE", "Palestine", []string{"Palestine, State of", "the State of Palestine"}},
+	{"PT", "PRT", "Portugal", []string{"Portuguese Republic"}},
+	{"PW", "PLW", "Palau", []string{"Republic of Palau"}},
+	{"PY", "PRY", "Paraguay", []string{"Republic of Paraguay"}},
+	{"QA", "QAT", "Qatar", []string{"State of Qatar"}},
+	{"RE", "REU", "Reunion", []string{"Réunion", "La Réunion"}},
+	{"RO", "ROU", "Romania", []string{"România"}},
+	{"RS", "SRB", "Serbia", []string{"Republic of Serbia", "Srbija", "Србија"}},
+	{"RU", "RUS", "Russia", []string{"Russian Federation", "Россия", "Rossiya"}},
+	{"RW", "RWA", "Rwanda", []string{"Rwandese Republic"}},
+	{"SA", "SAU", "Saudi Arabia", []string{"Kingdom of Saudi Arabia", "السعودية"}},
+	{"SB", "SLB", "Solomon Islands", []string{}},
+	{"SC", "SYC", "Seychelles", []string{"Republic of Seychelles"}},
+	{"SD", "SDN", "Sudan", []string{"Republic of the Sudan"}},
+	{"SE", "SWE", "Sweden", []string{"Kingdom of Sweden", "Sverige"}},
+	{"SG", "SGP", "Singapore", []string{"Republic of Singapore"}},
+	{"SH", "SHN", "Saint Helena, Ascension and Tristan da Cunha", []string{}},
+	{"SI", "SVN", "Slovenia", []string{"Republic of Slovenia", "Slovenija"}},
+	{"SJ", "SJM", "Svalbard and Jan Mayen", []string{}},
+	{"SK", "SVK", "Slovakia", []string{"Slovak Republic", "Slovensko"}},
+	{"SL", "SLE", "Sierra Leone", []string{"Republic of Sierra Leone"}},
+	{"SM", "SMR", "San Marino", []string{"Republic of San Marino"}},
+	{"SN", "SEN", "Senegal", []string{"Republic of Senegal"}},
+	{"SO", "SOM", "Somalia", []string{"Federal Republic of Somalia"}},
+	{"SR", "SUR", "Suriname", []string{"Republic of Suriname"}},
+	{"SS", "SSD", "South Sudan", []string{"Republic of South Sudan"}},
+	{"ST", "STP", "Sao Tome and Principe", []string{"Democratic Republic of Sao Tome and Principe"}},
+	{"SV", "SLV", "El Salvador", []string{"Republic of El Salvador"}},
+	{"SX", "SXM", "Sint Maarten (Dutch part)", []string{}},
+	{"SY", "SYR", "Syria", []string{"Syrian Arab Republic"}},
+	{"SZ", "SWZ", "Eswatini", []string{"Kingdom of Eswatini"}},
+	{"TC", "TCA", "Turks and Caicos Islands", []string{}},
+	{"TD", "TCD", "Chad", []string{"Republic of Chad"}},
+	{"TF", "ATF", "French Southern Territories", []string{}},
+	{"TG", "TGO", "Togo", []string{"Togolese Republic"}},
+	{"TH", "THA", "Thailand", []string{"Kingdom of Thailand"}},
+	{"TJ", "TJK", "Tajikistan", []string{"Republic of Tajikistan"}},
+	{"TK", "TKL", "Tokelau", []string{}},
+	{"TL", "TLS", "Timor-Leste", []string{"Democratic Republic of Timor-Leste"}},
+	{"TM", "TKM", "Turkmenistan", []string{}},
+	{"TN", "TUN", "Tunisia", []string{"Republic of Tunisia", "Tunisie"}},
+	{"TO", "TON", "Tonga", []string{"Kingdom of Tonga"}},
+	{"TR", "TUR", "Turkey", []string{"Türkiye", "Republic of Türkiye", "Turkiye"}},
+	{"TT", "TTO", "Trinidad and Tobago", []string{"Republic of Trinidad and Tobago"}},
+	{"TV", "TUV", "Tuvalu", []string{}},
+	{"TW", "TWN", "Taiwan", []string{"Taiwan, Province of China"}},
+	{"TZ", "TZA", "Tanzania", []string{"Tanzania, United Republic of", "United Republic of Tanzania"}},
+	{"UA", "UKR", "Ukraine", []string{"Україна", "Ukraina"}},
+	{"UG", "UGA", "Uganda", []string{"Republic of Uganda"}},
+	{"UM", "UMI", "United States Minor Outlying Islands", []string{}},
+	{"US", "USA", "United States", []string{"United States of America", "USA", "America"}},
+	{"UY", "URY", "Uruguay", []string{"Eastern Republic of Uruguay"}},
+	{"UZ", "UZB", "Uzbekistan", []string{"Republic of Uzbekistan"}},
+	{"VA", "VAT", "Vatican City", []string{"Holy See (Vatican City State)"}},
+	{"VC", "VCT", "Saint Vincent and the Grenadines", []string{}},
+	{"VE", "VEN", "Venezuela", []string{"Venezuela, Bolivarian Republic of", "Bolivarian Republic of Venezuela"}},
+	{"VG", "VGB", "Virgin Islands, British", []string{"British Virgin Islands"}},
+	{"VI", "VIR", "Virgin Islands, U.S.", []string{"Virgin Islands of the United States"}},
+	{"VN", "VNM", "Vietnam", []string{"Viet Nam", "Socialist Republic of Viet Nam"}},
+	{"VU", "VUT", "Vanuatu", []string{"Republic of Vanuatu"}},
+	{"WF", "WLF", "Wallis and Futuna", []string{"Wallis-et-Futuna"}},
+	{"WS", "WSM", "Samoa", []string{"Independent State of Samoa"}},
+	{"YE", "YEM", "Yemen", []string{"Republic of Yemen"}},
+	{"YT", "MYT", "Mayotte", []string{}},
+	{"ZA", "ZAF", "South Africa", []string{"Republic of South Africa"}},
+	{"ZM", "ZMB", "Zambia", []string{"Republic of Zambia"}},
+	{"ZW", "ZWE", "Zimbabwe", []string{"Republic of Zimbabwe"}},
+}
@@ -0,0 +1,74 @@
+// Package country resolves the countries given as ISO 3166-1 alpha-2 or alpha-3 codes, English
+// or local names into one canonical country, eg. "DEU", "de", "Deutschland" -> Germany
+package country
+
+import (
+	"fmt"
+
+	"github.com/tomekwlod/okpii/names"
+)
+
+// Country is a single ISO 3166-1 country
+type Country struct {
+	Alpha2 string
+	Alpha3 string
+
+	// Name is the canonical name, the same as in SciIQ (location.country_name) and in ES
+	Name string
+
+	// Other are the other names the country can be given with
+	Other []string
+}
+
+// lookup keys -> index of the country in the table; umlauts is the fallback for the transliterated
+// umlauts (Oesterreich), -1 marks a key of more countries
+var (
+	lookup  = map[string]int{}
+	umlauts = map[string]int{}
+)
+
+func init() {
+	for i, c := range countries {
+		for _, s := range append([]string{c.Alpha2, c.Alpha3, c.Name}, c.Other...) {
+			k := names.Key(s)
+
+			if j, ok := lookup[k]; ok && j != i {
+				panic(fmt.Sprintf("Country %s is ambiguous (%s, %s)", s, countries[j].Alpha3, c.Alpha3))
+			}
+
+			lookup[k] = i
+
+			u := names.UmlautKey(s)
+			if j, ok := umlauts[u]; ok && j != i {
+				umlauts[u] = -1
+				continue
+			}
+
+			umlauts[u] = i
+		}
+	}
+}
+
+// Resolve finds the country by its code or any of its names, case and accent insensitive. The umlauts
+// written as ae, oe, ue are tried only when the name isn't found as written
+func Resolve(s string) (c Country, ok bool) {
+	i, ok := lookup[names.Key(s)]
+	if !ok {
+		i, ok = umlauts[names.UmlautKey(s)]
+	}
+	if !ok || i < 0 {
+		return c, false
+	}
+
+	return countries[i], true
+}
+
+// Name returns the canonical name of the country, eg. "POL" -> "Poland"
+func Name(s string) (string, error) {
+	c, ok := Resolve(s)
+	if !ok {
+		return "", fmt.Errorf("Country %s not recognised", s)
+	}
+
+	return c.Name, nil
+}
@@ -0,0 +1,15 @@
+-- One-off migration of kol__onekey (created by the PHP project): removes the duplicated links made by
+-- the earlier runs of the matching and adds the unique key the matching relies on to link every OneKey
+-- to a KOL once. Run it once, before the first matching with the upsert; it fails if the key exists already.
+-- The first row of every (onekey, kid, did) is kept, the other columns are copied as they are
+
+CREATE TABLE kol__onekey_dedup LIKE kol__onekey;
+ALTER TABLE kol__onekey_dedup ADD UNIQUE KEY onekey_kid_did (onekey, kid, did);
+
+INSERT IGNORE INTO kol__onekey_dedup SELECT * FROM kol__onekey;
+
+-- atomic; the links written meanwhile would be lost, so don't run the matching at the same time
+RENAME TABLE kol__onekey TO kol__onekey_old, kol__onekey_dedup TO kol__onekey;
+
+-- once checked:
+-- DROP TABLE kol__onekey_old;
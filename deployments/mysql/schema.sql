@@ -0,0 +1,65 @@
+-- Tables owned by okpii. The SciIQ tables (kol, kol__onekey, location, ...) are managed by the PHP project
+
+-- every OneKey <-> KOL link found by the matching together with its provenance
+CREATE TABLE IF NOT EXISTS kol__onekey_match (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    run_id VARCHAR(32) NOT NULL,
+    onekey VARCHAR(32) NOT NULL,
+    kid INT NOT NULL,
+    did INT NOT NULL,
+    strategy VARCHAR(32) NOT NULL,
+    score DECIMAL(5,4) NOT NULL,
+    -- the matches which always need a review (eg. the fuzzy strategy)
+    low_confidence TINYINT(1) NOT NULL DEFAULT 0,
+    input_fn VARCHAR(255),
+    input_mn VARCHAR(255),
+    input_ln VARCHAR(255),
+    matched_fn VARCHAR(255),
+    matched_mn VARCHAR(255),
+    matched_ln VARCHAR(255),
+    created_at DATETIME NOT NULL,
+    UNIQUE KEY run_onekey_did_kid (run_id, onekey, did, kid)
+) DEFAULT CHARSET=utf8;
+
+-- for the tables created before the column:
+-- ALTER TABLE kol__onekey_match ADD COLUMN low_confidence TINYINT(1) NOT NULL DEFAULT 0 AFTER score;
+
+-- every execution of the matching command
+CREATE TABLE IF NOT EXISTS kol__onekey_run (
+    id VARCHAR(32) PRIMARY KEY,
+    status VARCHAR(16) NOT NULL,
+    deployments VARCHAR(255) NOT NULL,
+    processed INT NOT NULL DEFAULT 0,
+    matched INT NOT NULL DEFAULT 0,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NULL
+) DEFAULT CHARSET=utf8;
+
+-- last OneKey ID (SRC_CUST_ID) fully processed per run and deployment
+CREATE TABLE IF NOT EXISTS kol__onekey_checkpoint (
+    run_id VARCHAR(32) NOT NULL,
+    did INT NOT NULL,
+    onekey VARCHAR(32) NOT NULL,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (run_id, did)
+) DEFAULT CHARSET=utf8;
+
+-- OneKey people not matched because OneKey has more people with the same name signature
+CREATE TABLE IF NOT EXISTS kol__onekey_ambiguity (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    run_id VARCHAR(32) NOT NULL,
+    onekey VARCHAR(32) NOT NULL,
+    did INT NOT NULL,
+    strategy VARCHAR(32) NOT NULL,
+    signature VARCHAR(255) NOT NULL,
+    onekeys TEXT NOT NULL,
+    kids TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    UNIQUE KEY run_onekey_did_strategy (run_id, onekey, did, strategy)
+) DEFAULT CHARSET=utf8;
+
+-- high-water mark of the dumps to ES per deployment; the incremental dump re-indexes the KOLs updated since then
+CREATE TABLE IF NOT EXISTS kol__dump_mark (
+    did INT PRIMARY KEY,
+    synced_at DATETIME NOT NULL
+) DEFAULT CHARSET=utf8;
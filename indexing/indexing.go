@@ -0,0 +1,453 @@
+package indexing
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/tomekwlod/okpii/aliases"
+	modelsES "github.com/tomekwlod/okpii/models/es"
+	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
+)
+
+// lockName is the MySQL lock held by every dump, sync and rollback; only one of them can change
+// the experts index at a time
+const lockName = "okpii_experts_index"
+
+// Logger is satisfied by *log.Logger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Indexer dumps the experts from MySQL to ES. Every dump builds a new version of the experts index
+// and moves the experts alias to it only when the version is complete, so the matching always
+// searches a full index
+type Indexer struct {
+	ES    modelsES.Repository
+	MySQL modelsMysql.Repository
+
+	// Batch is the number of the experts fetched from MySQL and indexed at once
+	Batch int
+
+	// Keep is the number of the previous versions kept for a rollback; the older ones are deleted
+	Keep int
+
+	// Countries limits the dumped experts to the countries (all of them if empty)
+	Countries []string
+
+	// Nicknames are merged into the aliases of every indexed expert; nil indexes the expert's own aliases only
+	Nicknames *aliases.Dictionary
+
+	Logger Logger
+}
+
+// Report describes a finished dump
+type Report struct {
+	// Version is the new index behind the alias, Previous the one it replaced ("" on an empty cluster)
+	Version  string
+	Previous string
+
+	// Copied is the number of the experts of the other deployments copied from the previous version
+	Copied int64
+
+	// Experts is the number of the indexed experts per deployment
+	Experts map[int]int
+
+	// Pruned are the deleted old versions
+	Pruned []string
+
+	// Nicknames is the version of the nickname dictionary merged into the aliases
+	Nicknames string
+
+	Stats modelsES.IndexStats
+}
+
+func (ix *Indexer) logf(format string, v ...interface{}) {
+	if ix.Logger == nil {
+		log.Printf(format, v...)
+		return
+	}
+
+	ix.Logger.Printf(format, v...)
+}
+
+// Dump reindexes the deployments into a new version of the index. The experts of the other
+// deployments are copied from the current version. The new version is deleted if anything
+// fails (including a cancelled context) and the alias is left untouched. It fails straight away
+// when another dump, sync or rollback is running
+func (ix *Indexer) Dump(ctx context.Context, dids []int) (report *Report, err error) {
+	unlock, err := ix.lock()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	current, err := ix.ES.CurrentVersion(ctx)
+	if err != nil {
+		return
+	}
+
+	// the changes made in MySQL during the dump are picked up by the next incremental one
+	started, err := ix.MySQL.Now()
+	if err != nil {
+		return
+	}
+
+	version, err := ix.ES.CreateVersion(ctx)
+	if err != nil {
+		return
+	}
+	ix.logf("Building %s (current: %s)", version, current)
+
+	report = &Report{Version: version, Previous: current, Experts: map[int]int{}, Nicknames: ix.nicknames()}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		// the context may be cancelled already; the clean up has to happen anyway
+		if derr := ix.ES.DeleteVersion(context.Background(), version); derr != nil {
+			ix.logf("Couldn't delete the unfinished %s: %v", version, derr)
+		}
+	}()
+
+	if current != "" {
+		report.Copied, err = ix.ES.CopyDeployments(ctx, current, version, dids)
+		if err != nil {
+			return
+		}
+		ix.logf("Copied %d experts of the other deployments from %s", report.Copied, current)
+	}
+
+	failed := &modelsES.BulkError{}
+
+	for _, did := range dids {
+		fetch := func(lastID int) (int, []*modelsMysql.Experts, error) {
+			return ix.MySQL.FetchExperts(lastID, did, ix.Batch, ix.Countries)
+		}
+
+		before := len(failed.Failures)
+
+		var fetched int
+		fetched, report.Experts[did], err = ix.index(ctx, version, fetch, &report.Stats, failed)
+		if err != nil {
+			return
+		}
+
+		if len(failed.Failures) > before {
+			// the version won't be made live; the refused experts are reported at the end
+			ix.logf("Deployment %d: %d experts refused by ES", did, len(failed.Failures)-before)
+			continue
+		}
+
+		// validating the version against what has been read from MySQL
+		var count int64
+		count, err = ix.ES.CountVersion(ctx, version, did)
+		if err != nil {
+			return
+		}
+		if count != int64(fetched) {
+			err = fmt.Errorf("Deployment %d: %d experts in %s, %d read from MySQL", did, count, version, fetched)
+			return
+		}
+
+		ix.logf("Deployment %d: %d experts indexed", did, count)
+	}
+
+	// a version without some experts is never swapped; all the failures are returned to be fixed at once
+	if len(failed.Failures) > 0 {
+		err = failed
+		return
+	}
+
+	// the lock keeps the other dumps away; anything else moving the alias meanwhile (eg. by hand)
+	// would be silently overwritten
+	live, err := ix.ES.CurrentVersion(ctx)
+	if err != nil {
+		return
+	}
+	if live != current {
+		err = fmt.Errorf("Alias moved from %s to %s during the dump; %s is not made live", current, live, version)
+		return
+	}
+
+	err = ix.ES.SwapAlias(ctx, current, version)
+	if err != nil {
+		return
+	}
+	ix.logf("Alias moved from %s to %s", current, version)
+
+	// a dump limited to some countries can't be continued incrementally
+	if len(ix.Countries) == 0 {
+		for _, did := range dids {
+			if err = ix.MySQL.SaveSyncMark(did, started); err != nil {
+				// the dump is live anyway; the next incremental dump will only repeat more work
+				ix.logf("Couldn't save the mark of the deployment %d: %v", did, err)
+				err = nil
+			}
+		}
+	}
+
+	// from now on the dump is done; failing to prune is only reported
+	report.Pruned, _ = ix.prune(ctx, current)
+
+	return report, nil
+}
+
+// index indexes the experts returned page by page by fetch. The experts refused by ES are
+// collected in failed and the indexing continues; fetched counts the experts read from MySQL,
+// indexed only the ones ES reported as indexed
+func (ix *Indexer) index(ctx context.Context, index string, fetch func(lastID int) (int, []*modelsMysql.Experts, error), stats *modelsES.IndexStats, failed *modelsES.BulkError) (fetched, indexed int, err error) {
+	var experts []*modelsMysql.Experts
+
+	lastID := 0
+	for {
+		// getting the experts from the MySQL
+		lastID, experts, err = fetch(lastID)
+		if err != nil {
+			return
+		}
+
+		// stop if no results
+		if len(experts) == 0 {
+			return
+		}
+		fetched += len(experts)
+
+		for _, e := range experts {
+			e.Aliases = ix.Nicknames.Merge(e.Fn, e.Aliases)
+		}
+
+		// indexing the experts onto ES
+		var s modelsES.IndexStats
+		s, err = ix.ES.IndexExperts(ctx, index, experts, ix.Batch)
+		stats.Add(s)
+		indexed += int(s.Indexed)
+
+		if be, ok := err.(*modelsES.BulkError); ok {
+			failed.Add(be)
+			err = nil
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// nicknames returns the version of the nickname dictionary, "" without one
+func (ix *Indexer) nicknames() string {
+	if ix.Nicknames == nil {
+		return ""
+	}
+
+	return ix.Nicknames.Version
+}
+
+// Lock takes the lock of the experts index held by the dumps; every other write to the live index
+// (eg. the REST updates) takes it too, otherwise the write would be lost with the swap of the alias.
+// It fails straight away while a dump is running; the returned function releases the lock
+func Lock(mysql modelsMysql.Repository) (func(), error) {
+	unlock, err := mysql.Lock(lockName, 0)
+	if err != nil {
+		return nil, fmt.Errorf("Another dump is running (%v); try again once it's finished", err)
+	}
+
+	return unlock, nil
+}
+
+func (ix *Indexer) lock() (func(), error) {
+	return Lock(ix.MySQL)
+}
+
+// prune deletes the versions older than the Keep previous ones. Only the versions older than the
+// previous live one are deleted; the previous one is always kept (it counts as one of Keep)
+func (ix *Indexer) prune(ctx context.Context, previous string) (pruned []string, err error) {
+	if previous == "" {
+		return
+	}
+
+	versions, err := ix.ES.Versions(ctx)
+	if err != nil {
+		ix.logf("Couldn't list the versions: %v", err)
+		return
+	}
+
+	var older []string
+	for _, v := range versions {
+		if v < previous {
+			older = append(older, v)
+		}
+	}
+
+	// the previous version is one of the kept ones
+	keep := ix.Keep - 1
+	if keep < 0 {
+		keep = 0
+	}
+
+	for i := 0; i < len(older)-keep; i++ {
+		if err = ix.ES.DeleteVersion(ctx, older[i]); err != nil {
+			ix.logf("Couldn't delete %s: %v", older[i], err)
+			continue
+		}
+
+		pruned = append(pruned, older[i])
+	}
+
+	return
+}
+
+// Migrate copies the live index into a new version created with the mapping from the binary and
+// makes it live. The fields computed by the dump aren't filled for the existing experts; run a full
+// dump of all the deployments instead when the new mapping needs them
+func (ix *Indexer) Migrate(ctx context.Context) (*Report, error) {
+	return ix.Dump(ctx, nil)
+}
+
+// SyncReport describes a finished incremental dump
+type SyncReport struct {
+	// Updated is the number of the re-indexed experts per deployment
+	Updated map[int]int
+
+	// Deleted is the number of the experts marked as deleted per deployment
+	Deleted map[int]int
+
+	// Nicknames is the version of the nickname dictionary merged into the aliases of the updated experts
+	Nicknames string
+
+	Stats modelsES.IndexStats
+}
+
+// Sync re-indexes in the live index only the experts updated in MySQL since the last successful
+// dump of the deployment and marks the experts which aren't in MySQL anymore as deleted. A deployment
+// has to be dumped fully once before; the mark is moved only when the deployment is synced completely
+func (ix *Indexer) Sync(ctx context.Context, dids []int) (report *SyncReport, err error) {
+	if len(ix.Countries) > 0 {
+		return nil, fmt.Errorf("The incremental dump works only for all the countries")
+	}
+
+	// a full dump running meanwhile would make live a version copied before these changes
+	unlock, err := ix.lock()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	report = &SyncReport{Updated: map[int]int{}, Deleted: map[int]int{}, Nicknames: ix.nicknames()}
+	failed := &modelsES.BulkError{}
+
+	for _, did := range dids {
+		var mark, started time.Time
+
+		mark, err = ix.MySQL.SyncMark(did)
+		if err != nil {
+			return
+		}
+		if mark.IsZero() {
+			return report, fmt.Errorf("Deployment %d has never been dumped fully; run a full dump first", did)
+		}
+
+		started, err = ix.MySQL.Now()
+		if err != nil {
+			return
+		}
+
+		fetch := func(lastID int) (int, []*modelsMysql.Experts, error) {
+			return ix.MySQL.FetchChangedExperts(lastID, did, ix.Batch, nil, mark)
+		}
+
+		// straight to the live index; a document is replaced as a whole
+		before := len(failed.Failures)
+		_, report.Updated[did], err = ix.index(ctx, "", fetch, &report.Stats, failed)
+		if err != nil {
+			return
+		}
+
+		report.Deleted[did], err = ix.markDeleted(ctx, did)
+		if err != nil {
+			return
+		}
+
+		ix.logf("Deployment %d: %d experts updated, %d deleted since %s", did, report.Updated[did], report.Deleted[did], mark.Format("2006-01-02 15:04:05"))
+
+		if len(failed.Failures) > before {
+			// the failed experts have to be picked up by the next run again
+			ix.logf("Deployment %d: %d experts failed, the mark is kept", did, len(failed.Failures)-before)
+			continue
+		}
+
+		if err = ix.MySQL.SaveSyncMark(did, started); err != nil {
+			return
+		}
+	}
+
+	if len(failed.Failures) > 0 {
+		return report, failed
+	}
+
+	return
+}
+
+// markDeleted marks the experts indexed in ES but removed from MySQL as deleted
+func (ix *Indexer) markDeleted(ctx context.Context, did int) (deleted int, err error) {
+	indexed, err := ix.ES.ExpertIDs(ctx, did)
+	if err != nil {
+		return
+	}
+
+	existing, err := ix.MySQL.ExpertIDs(did)
+	if err != nil {
+		return
+	}
+
+	for _, id := range indexed {
+		if existing[id] {
+			continue
+		}
+
+		if err = ix.ES.MarkAsDeleted(ctx, strconv.Itoa(id)); err != nil {
+			return
+		}
+
+		deleted++
+	}
+
+	return
+}
+
+// Rollback moves the alias back to the version preceding the live one and returns both of them.
+// The live version is kept, so a rollback can be undone with the next dump only
+func (ix *Indexer) Rollback(ctx context.Context) (from, to string, err error) {
+	unlock, err := ix.lock()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	from, err = ix.ES.CurrentVersion(ctx)
+	if err != nil {
+		return
+	}
+
+	versions, err := ix.ES.Versions(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, v := range versions {
+		if v < from {
+			to = v
+		}
+	}
+
+	if to == "" {
+		return from, "", fmt.Errorf("No version older than %s to roll back to", from)
+	}
+
+	err = ix.ES.SwapAlias(ctx, from, to)
+
+	return
+}
@@ -0,0 +1,148 @@
+package matching
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Config tunes the pipeline without recompiling; it can be loaded from a JSON file and overridden by the flags
+//
+//	{
+//	  "collectAll": false,
+//	  "cityAware": true,
+//	  "disabled": ["madness"],
+//	  "maxResults": {"simple": 2, "short": 1}
+//	}
+type Config struct {
+	// CollectAll collects the matches from every step instead of stopping on the first step with a match
+	CollectAll bool `json:"collectAll"`
+
+	// CityAware boosts the candidates from the same city and uses the city to pick a single candidate
+	// when a risky strategy (nomid, madness) finds more of them
+	CityAware bool `json:"cityAware"`
+
+	// Strategies are the enabled strategies in the order they run; empty means all the registered ones
+	Strategies []string `json:"strategies,omitempty"`
+
+	// Disabled strategies are skipped even if listed in Strategies
+	Disabled []string `json:"disabled,omitempty"`
+
+	// MaxResults rejects all the matches of the strategy (per deployment) if there are more of them; 0 means no limit
+	MaxResults map[string]int `json:"maxResults,omitempty"`
+}
+
+// DefaultConfig is the configuration used when nothing else is given
+func DefaultConfig() Config {
+	return Config{
+		// before, it was a return when we had a match in any of the steps
+		// collecting from every step may bring more matches but at the same time it is more risky
+		CollectAll: true,
+		MaxResults: map[string]int{
+			"simple": 2,
+		},
+	}
+}
+
+// LoadConfig reads the JSON file on top of the default configuration. Every field given in the file
+// replaces the default one as a whole, eg. "maxResults": {} removes the default caps
+func LoadConfig(filename string) (cfg Config, err error) {
+	cfg = DefaultConfig()
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return cfg, fmt.Errorf("Config %s couldn't be parsed: %v", filename, err)
+	}
+
+	// a map would be merged into the default one by the decoder
+	for field := range fields {
+		if strings.EqualFold(field, "maxResults") {
+			cfg.MaxResults = nil
+		}
+	}
+
+	if err = json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("Config %s couldn't be parsed: %v", filename, err)
+	}
+
+	return
+}
+
+// ParseMaxResults parses the limits in the form of "simple=2,short=1" into the config
+func (c *Config) ParseMaxResults(s string) error {
+	if c.MaxResults == nil {
+		c.MaxResults = map[string]int{}
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("Limit %s should be in the form of strategy=number", pair)
+		}
+
+		max, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return fmt.Errorf("Limit %s should be in the form of strategy=number", pair)
+		}
+
+		c.MaxResults[strings.TrimSpace(kv[0])] = max
+	}
+
+	return nil
+}
+
+// Configure returns the pipeline of the enabled strategies set up by the config
+func (r *Registry) Configure(env *Env, cfg Config) (*Pipeline, error) {
+	disabled := map[string]bool{}
+	for _, name := range cfg.Disabled {
+		if _, ok := r.strategies[name]; !ok {
+			return nil, fmt.Errorf("Strategy %s not registered", name)
+		}
+
+		disabled[name] = true
+	}
+
+	names := cfg.Strategies
+	if len(names) == 0 {
+		names = r.names
+	}
+
+	enabled := []string{}
+	for _, name := range names {
+		if !disabled[name] {
+			enabled = append(enabled, name)
+		}
+	}
+
+	if len(enabled) == 0 {
+		return nil, fmt.Errorf("No strategy enabled")
+	}
+
+	for name := range cfg.MaxResults {
+		if _, ok := r.strategies[name]; !ok {
+			return nil, fmt.Errorf("Strategy %s not registered", name)
+		}
+	}
+
+	p, err := r.Pipeline(env, enabled...)
+	if err != nil {
+		return nil, err
+	}
+
+	p.CollectAll = cfg.CollectAll
+	p.CityAware = cfg.CityAware
+	p.maxResults = cfg.MaxResults
+
+	return p, nil
+}
@@ -0,0 +1,120 @@
+package matching
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	modelsES "github.com/tomekwlod/okpii/models/es"
+)
+
+// Explanation is the debug trace of a pipeline run, see WithExplain. It's meant for investigating
+// the disputed matches, not for the bulk runs; every ES search is made with the ES explain
+type Explanation struct {
+	Steps []*StepExplanation `json:"steps"`
+
+	trace   *modelsES.Trace
+	current *StepExplanation
+	filter  *FilterExplanation
+}
+
+// StepExplanation is what a single strategy did
+type StepExplanation struct {
+	Strategy     string `json:"strategy"`
+	Precondition bool   `json:"precondition"`
+
+	// Searches are the ES searches of the strategy, one per deployment
+	Searches []modelsES.TracedSearch `json:"searches,omitempty"`
+
+	// Filters are the post-filters run per deployment, in the order
+	Filters []*FilterExplanation `json:"filters,omitempty"`
+}
+
+// FilterExplanation is what a single post-filter did with the candidates of one deployment
+type FilterExplanation struct {
+	Filter string `json:"filter"`
+	Did    int    `json:"did"`
+	In     []int  `json:"in"`
+	Out    []int  `json:"out"`
+
+	// Reasons tell why the candidates were rejected
+	Reasons []string `json:"reasons,omitempty"`
+
+	// Searches are the ES searches made by the filter (eg. looking for the other people with the same initials)
+	Searches []modelsES.TracedSearch `json:"searches,omitempty"`
+}
+
+type explainKey struct{}
+
+// WithExplain returns a context making the pipeline record everything it does in the explanation
+func WithExplain(ctx context.Context) (context.Context, *Explanation) {
+	e := &Explanation{trace: &modelsES.Trace{}}
+
+	ctx = context.WithValue(ctx, explainKey{}, e)
+	ctx = modelsES.WithTrace(ctx, e.trace)
+
+	return ctx, e
+}
+
+func explanationFrom(ctx context.Context) *Explanation {
+	e, _ := ctx.Value(explainKey{}).(*Explanation)
+
+	return e
+}
+
+// step starts the explanation of a strategy
+func (e *Explanation) step(strategy string, precondition bool) *StepExplanation {
+	// whatever has been searched before doesn't belong to the step
+	e.trace.Take()
+
+	e.current = &StepExplanation{Strategy: strategy, Precondition: precondition}
+	e.Steps = append(e.Steps, e.current)
+
+	return e.current
+}
+
+// searched assigns the searches made since the step started to the step
+func (e *Explanation) searched() {
+	e.current.Searches = e.trace.Take()
+}
+
+// filtering starts the explanation of a post-filter of the current step; the reasons given with reject go to it
+func (e *Explanation) filtering(filter string, did int, rows []*modelsES.ExpertHit) *FilterExplanation {
+	e.trace.Take()
+
+	f := &FilterExplanation{Filter: filter, Did: did, In: ids(rows)}
+	e.current.Filters = append(e.current.Filters, f)
+	e.filter = f
+
+	return f
+}
+
+// filtered closes the explanation of the post-filter
+func (e *Explanation) filtered(f *FilterExplanation, rows []*modelsES.ExpertHit) {
+	f.Out = ids(rows)
+	f.Searches = e.trace.Take()
+	e.filter = nil
+}
+
+// reject logs why the candidates were rejected; with an explanation the reason is recorded as well
+func (e *Env) reject(ctx context.Context, format string, v ...interface{}) {
+	e.logf(format, v...)
+
+	if ex := explanationFrom(ctx); ex != nil && ex.filter != nil {
+		ex.filter.Reasons = append(ex.filter.Reasons, strings.TrimSpace(fmt.Sprintf(format, v...)))
+	}
+}
+
+// filterName returns the name of the filter function, eg. "noMorePeopleLikeInitials"
+func filterName(f Filter) string {
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+
+	// closures, eg. "matching.uniqueInOneKey.func1"
+	if i := strings.Index(name, ".func"); i > 0 {
+		name = name[:i]
+	}
+
+	return name[strings.LastIndex(name, ".")+1:]
+}
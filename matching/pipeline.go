@@ -0,0 +1,217 @@
+package matching
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	modelsES "github.com/tomekwlod/okpii/models/es"
+)
+
+// Match is a single candidate found by one of the strategies
+type Match struct {
+	Strategy string
+	ID       int
+	Did      int
+
+	// Score is the confidence of the match between 0 and 1, see AutoAcceptScore
+	Score float64
+
+	// LowConfidence is set for the matches of the strategies marked with LowConfidence (eg. fuzzy)
+	LowConfidence bool
+
+	Expert *modelsES.ExpertHit
+}
+
+// AutoAccept tells if the match is confident enough to be accepted without a review
+func (m Match) AutoAccept() bool {
+	return !m.LowConfidence && m.Score >= AutoAcceptScore
+}
+
+// Ambiguity is a match which hasn't been made because OneKey contains more people with the same
+// name signature; the team can decide to unmerge the experts or to ignore it
+type Ambiguity struct {
+	Strategy string
+	Did      int
+
+	// Signature is the name shared by the OneKey people, eg. "J Smith"
+	Signature string
+
+	// Onekeys are the other OneKey IDs (SRC_CUST_ID) sharing the signature
+	Onekeys []string
+
+	// KIDs are the candidates which would have been matched
+	KIDs []int
+}
+
+// Result holds everything the pipeline found for one input
+type Result struct {
+	Matches     []Match
+	Ambiguities []Ambiguity
+}
+
+// Pipeline runs the strategies one-by-one in the given order
+type Pipeline struct {
+	env        *Env
+	strategies []Strategy
+
+	// CollectAll makes the pipeline collect the matches from every step. With false a deployment
+	// is not searched anymore after the first step with any match in it; it is safer but brings fewer matches
+	CollectAll bool
+
+	// CityAware makes the strategies use the city of the input, see Config.CityAware
+	CityAware bool
+
+	// strategy -> the max number of the matches per deployment, see Config.MaxResults
+	maxResults map[string]int
+}
+
+// Strategies returns the names of the strategies in the pipeline order
+func (p *Pipeline) Strategies() (names []string) {
+	for _, s := range p.strategies {
+		names = append(names, s.Name())
+	}
+
+	return
+}
+
+// Run runs the strategies for the input; it stops with the context's error once the context is done
+func (p *Pipeline) Run(ctx context.Context, in Input) (*Result, error) {
+	result := &Result{}
+
+	if strings.Replace(in.Fn, " ", "", -1) == "" {
+		// if no FN we should just continue; it causes too much hassle
+		return result, nil
+	}
+
+	exclIDs := append([]string{}, in.ExclIDs...)
+
+	// the deployments still to be searched
+	dids := append([]int{}, in.Dids...)
+	if len(dids) == 0 {
+		// the whole index at once
+		dids = []int{0}
+	}
+
+	explain := explanationFrom(ctx)
+
+	for _, s := range p.strategies {
+		if len(dids) == 0 {
+			// every deployment got its match already
+			break
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		pre := s.Precondition(in.Fn, in.Mn, in.Ln)
+		if explain != nil {
+			explain.step(s.Name(), pre)
+		}
+
+		if !pre {
+			continue
+		}
+
+		step := in
+		step.Dids = searchable(dids)
+		step.ExclIDs = exclIDs
+		step.result = result
+		step.cityAware = p.CityAware
+		step.strategy = s.Name()
+		step.nicknames = p.env.Nicknames.Nicknames(in.Fn)
+
+		found, err := s.Search(ctx, p.env, step)
+		if err != nil {
+			return nil, err
+		}
+
+		if explain != nil {
+			explain.searched()
+		}
+
+		matched := map[int]bool{}
+
+		for _, did := range dids {
+			rows := found[did]
+			if len(rows) == 0 {
+				continue
+			}
+
+			// the post-filters and the score work per deployment
+			one := step
+			one.Dids = searchable([]int{did})
+
+			rows, err = s.PostFilter(ctx, p.env, one, rows)
+			if err != nil {
+				return nil, err
+			}
+
+			if max := p.maxResults[s.Name()]; max > 0 && len(rows) > max {
+				var f *FilterExplanation
+				if explain != nil {
+					f = explain.filtering("maxResults", did, rows)
+				}
+
+				p.env.reject(ctx, "[%s] Too many matches (%d) in did:%d\n", s.Name(), len(rows), did)
+
+				if explain != nil {
+					explain.filtered(f, nil)
+				}
+
+				continue
+			}
+
+			for _, row := range rows {
+				id := row.ID
+				if id == 0 {
+					p.env.logf("[%s] ID not valid: %s\n", s.Name(), row.DocID)
+					continue
+				}
+
+				// already matched experts cannot be matched again by the next steps
+				exclIDs = append(exclIDs, strconv.Itoa(id))
+				matched[did] = true
+
+				rowDid := did
+				if rowDid == 0 {
+					rowDid = row.Did
+				}
+
+				row.Strategy = s.Name()
+
+				result.Matches = append(result.Matches, Match{
+					Strategy:      s.Name(),
+					ID:            id,
+					Did:           rowDid,
+					Score:         score(s, one, row, rows),
+					LowConfidence: isLowConfidence(s),
+					Expert:        row,
+				})
+			}
+		}
+
+		if !p.CollectAll {
+			// a match -> the deployment keeps the matches from one search only
+			left := []int{}
+			for _, did := range dids {
+				if !matched[did] {
+					left = append(left, did)
+				}
+			}
+			dids = left
+		}
+	}
+
+	return result, nil
+}
+
+// searchable turns the internal did 0 (the whole index) back into no deployments
+func searchable(dids []int) []int {
+	if len(dids) == 1 && dids[0] == 0 {
+		return nil
+	}
+
+	return dids
+}
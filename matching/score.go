@@ -0,0 +1,82 @@
+package matching
+
+import (
+	"strings"
+
+	modelsES "github.com/tomekwlod/okpii/models/es"
+	"github.com/tomekwlod/okpii/names"
+)
+
+// AutoAcceptScore is the score above which a match can be accepted without a manual review
+const AutoAcceptScore = 0.8
+
+// score combines the strategy weight with the signals we have about the candidate. It returns
+// a value between 0 and 1; the higher the more confident the match is
+//
+//   - ES _score, relative to the best hit of the same step
+//   - city agreement (the country is already filtered by the ES query when provided)
+//   - alias usage; matching by an alias is less certain than matching by the first name
+//   - how many alternatives the step returned
+func score(s Strategy, in Input, row *modelsES.ExpertHit, rows []*modelsES.ExpertHit) float64 {
+	sc := s.Weight()
+
+	// ES _score
+	var max float64
+	for _, r := range rows {
+		if r.Score > max {
+			max = r.Score
+		}
+	}
+	if max > 0 {
+		sc -= 0.1 * (1 - row.Score/max)
+	}
+
+	// city
+	if in.City != "" && row.City != "" {
+		if names.Same(in.City, row.City) {
+			sc += 0.1
+		} else {
+			sc -= 0.1
+		}
+	}
+
+	// alias
+	if usedAlias(in, row) {
+		sc -= 0.1
+	}
+
+	// alternatives
+	if len(rows) > 1 {
+		sc -= 0.1 * float64(len(rows)-1)
+	}
+
+	if sc < 0 {
+		return 0
+	}
+	if sc > 1 {
+		return 1
+	}
+
+	return sc
+}
+
+// usedAlias tells if the first name matched only through one of the expert's aliases or one of its nicknames
+func usedAlias(in Input, row *modelsES.ExpertHit) bool {
+	if in.Fn == "" || strings.EqualFold(in.Fn, row.Fn) {
+		return false
+	}
+
+	for _, alias := range row.Aliases {
+		if strings.EqualFold(in.Fn, alias) {
+			return true
+		}
+	}
+
+	for _, nick := range in.nicknames {
+		if strings.EqualFold(nick, row.Fn) {
+			return true
+		}
+	}
+
+	return false
+}
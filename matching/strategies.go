@@ -0,0 +1,441 @@
+package matching
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	modelsES "github.com/tomekwlod/okpii/models/es"
+	"github.com/tomekwlod/okpii/names"
+	strutils "github.com/tomekwlod/utils/strings"
+	elastic "gopkg.in/olivere/elastic.v6"
+)
+
+// searchFunc has the signature of every Repository search, eg. modelsES.Repository.SimpleSearch
+type searchFunc func(es modelsES.Repository, ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*modelsES.ExpertHit, error)
+
+// Filter is a post-filter; it returns only the rows which are safe to be matched
+type Filter func(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error)
+
+// step is a generic Strategy built from a search function, a precondition and the post-filters
+type step struct {
+	name    string
+	weight  float64
+	pre     func(fn, mn, ln string) bool
+	search  searchFunc
+	filters []Filter
+}
+
+// NewStrategy builds a Strategy out of the given functions. pre can be nil
+func NewStrategy(name string, weight float64, pre func(fn, mn, ln string) bool, search searchFunc, filters ...Filter) Strategy {
+	return &step{name: name, weight: weight, pre: pre, search: search, filters: filters}
+}
+
+func (s *step) Name() string {
+	return s.name
+}
+
+func (s *step) Weight() float64 {
+	return s.weight
+}
+
+func (s *step) Precondition(fn, mn, ln string) bool {
+	if s.pre == nil {
+		return true
+	}
+
+	return s.pre(fn, mn, ln)
+}
+
+func (s *step) Search(ctx context.Context, env *Env, in Input) (map[int][]*modelsES.ExpertHit, error) {
+	// the city is used by the ES only in the city-aware mode
+	city := ""
+	if in.cityAware {
+		city = in.City
+	}
+
+	return s.search(env.ES, ctx, in.Fn, in.Mn, in.Ln, in.Country, city, in.Dids, in.ExclIDs)
+}
+
+func (s *step) PostFilter(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) (_ []*modelsES.ExpertHit, err error) {
+	explain := explanationFrom(ctx)
+
+	for _, filter := range s.filters {
+		if len(rows) == 0 {
+			break
+		}
+
+		var f *FilterExplanation
+		if explain != nil {
+			f = explain.filtering(filterName(filter), in.did(), rows)
+		}
+
+		rows, err = filter(ctx, env, in, rows)
+		if err != nil {
+			return nil, err
+		}
+
+		if explain != nil {
+			explain.filtered(f, rows)
+		}
+	}
+
+	return rows, nil
+}
+
+// builtin returns all the strategies in the default order. The order matters; the safest ones go first
+func builtin() []Strategy {
+	return []Strategy{
+		NewStrategy("simple", 1.0, nil, modelsES.Repository.SimpleSearch),
+		NewStrategy("foreign", 0.95, nil, modelsES.Repository.ForeignSearch,
+			notOnlyASCII,
+		),
+		NewStrategy("short", 0.85, withMiddleName, modelsES.Repository.ShortSearch,
+			noMorePeopleLikeInitials,
+		),
+		NewStrategy("nomid", 0.75, noMiddleName, modelsES.Repository.NoMiddleNameSearch,
+			oneOrSameCity,
+			noMorePeopleLikeFirstInitial,
+			uniqueInOneKey(true),
+		),
+		NewStrategy("onemid1", 0.75, noMiddleNameFullFirstName, modelsES.Repository.OneMiddleNameSearch,
+			uniqueMiddleNames,
+			noMorePeopleLikeFullName,
+			uniqueInOneKey(false),
+		),
+		NewStrategy("onemid2", 0.75, withMiddleName, modelsES.Repository.OneMiddleNameSearch2,
+			noPeopleWithMiddleName,
+			uniqueInOneKey(false),
+		),
+		NewStrategy("madness", 0.6, initialOnly, modelsES.Repository.MadnessSearch,
+			oneOrSameCity,
+		),
+		NewStrategy("threein", 0.7, noMiddleNameFullFirstName, modelsES.Repository.ThreeInitialsSearch),
+		// after all the exact ones; the names only sound alike
+		NewStrategy("phonetic", 0.5, fullNames, modelsES.Repository.PhoneticSearch,
+			oneOrSameCity,
+			noMorePeopleSoundingAlike,
+			uniqueInOneKey(false),
+		),
+		// the last resort; the matches always need a review
+		LowConfidence(NewStrategy("fuzzy", 0.4, fullNames, modelsES.Repository.FuzzySearch,
+			onlyOne,
+			uniqueInOneKey(false),
+		)),
+	}
+}
+
+// preconditions
+
+func withMiddleName(fn, mn, ln string) bool {
+	return mn != ""
+}
+
+func noMiddleName(fn, mn, ln string) bool {
+	return mn == ""
+}
+
+func noMiddleNameFullFirstName(fn, mn, ln string) bool {
+	return mn == "" && strutils.Length(fn) > 1
+}
+
+func initialOnly(fn, mn, ln string) bool {
+	return strutils.Length(fn) == 1
+}
+
+// fullNames rejects the initials and the very short last names; they sound like too many others
+func fullNames(fn, mn, ln string) bool {
+	return strutils.Length(fn) > 1 && strutils.Length(ln) > 3
+}
+
+// post-filters
+
+// oneOrSameCity rejects the rows if there is more than one of them. In the city-aware mode the only
+// candidate from the input's city is picked instead
+func oneOrSameCity(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error) {
+	if len(rows) <= 1 {
+		return rows, nil
+	}
+
+	if local := sameCity(in, rows); len(local) == 1 {
+		env.reject(ctx, "[%s] %d candidates, picked %d from the same city %s\n", in.strategy, len(rows), local[0].ID, in.City)
+
+		return local, nil
+	}
+
+	env.reject(ctx, "[%s] %d candidates (%v), only one is allowed\n", in.strategy, len(rows), ids(rows))
+
+	return nil, nil
+}
+
+// onlyOne rejects the rows if there is more than one of them, no matter the city
+func onlyOne(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error) {
+	if len(rows) > 1 {
+		env.reject(ctx, "[%s] %d candidates (%v), only one is allowed\n", in.strategy, len(rows), ids(rows))
+
+		return nil, nil
+	}
+
+	return rows, nil
+}
+
+// notOnlyASCII rejects the rows if neither the input nor the matches contain any German or
+// other country specific characters; the ForeignSearch doesn't make sense then
+func notOnlyASCII(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error) {
+	names := []string{in.Fn + " " + in.Mn + " " + in.Ln}
+	isASCII := strutils.IsASCII(in.Fn + in.Mn + in.Ln)
+
+	for _, row := range rows {
+		name := row.Fn + row.Mn + row.Ln
+		names = append(names, name)
+
+		if !strutils.IsASCII(name) {
+			isASCII = false
+		}
+	}
+
+	if isASCII {
+		env.reject(ctx, "[ForeignSearch] BLOCKED because it is not ASCII name %s \n", strings.Join(names, " ;; "))
+
+		return nil, nil
+	}
+
+	return rows, nil
+}
+
+// noMorePeopleLikeInitials rejects the rows if there are other people like F* M* Ln
+func noMorePeopleLikeInitials(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error) {
+	exclIDs := append([]string{}, in.ExclIDs...)
+	for _, row := range rows {
+		exclIDs = append(exclIDs, fmt.Sprint(row.ID))
+	}
+
+	q, err := env.ES.BaseQuery(in.did(), in.Country, exclIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	q.Must(elastic.NewMatchPhraseQuery("ln", in.Ln))
+	q.Must(elastic.NewPrefixQuery("fn", strutils.FirstChar(in.Fn)))
+	q.Must(elastic.NewPrefixQuery("mn", strutils.FirstChar(in.Mn)))
+
+	others, err := env.ES.ExecuteQuery(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(others) > 1 {
+		env.reject(ctx, "[ShortSearch] There are more people like %s* %s* %s (%v)\n", strutils.FirstChar(in.Fn), strutils.FirstChar(in.Mn), in.Ln, ids(others))
+
+		return nil, nil
+	}
+
+	return rows, nil
+}
+
+// noMorePeopleLikeFirstInitial rejects the rows if there are other people like F* Ln
+func noMorePeopleLikeFirstInitial(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error) {
+	q, err := env.ES.BaseQuery(in.did(), in.Country, in.ExclIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	q.Must(elastic.NewMatchPhraseQuery("ln", in.Ln))
+	q.Must(elastic.NewPrefixQuery("fn", strutils.FirstChar(in.Fn)))
+
+	others, err := env.ES.ExecuteQuery(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(others) > 1 {
+		// the only one from the same city (and it's our candidate) is still fine in the city-aware mode
+		if local := sameCity(in, others); len(local) == 1 && len(rows) == 1 && local[0].ID == rows[0].ID {
+			return rows, nil
+		}
+
+		env.reject(ctx, "[NoMiddleNameSearch] There are more people like %s* %s (%v)\n", strutils.FirstChar(in.Fn), in.Ln, ids(others))
+
+		return nil, nil
+	}
+
+	return rows, nil
+}
+
+// noMorePeopleSoundingAlike rejects the rows if there are other people with the same first initial
+// whose last name sounds the same
+func noMorePeopleSoundingAlike(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error) {
+	q, err := env.ES.BaseQuery(in.did(), in.Country, in.ExclIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	q.Must(elastic.NewTermQuery("lnPhonetic", names.Cologne(in.Ln)))
+	q.Must(elastic.NewPrefixQuery("fn", strutils.FirstChar(in.Fn)))
+
+	others, err := env.ES.ExecuteQuery(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(others) > 1 {
+		env.reject(ctx, "[PhoneticSearch] There are more people like %s* %s (%s) (%v)\n", strutils.FirstChar(in.Fn), in.Ln, names.Cologne(in.Ln), ids(others))
+
+		return nil, nil
+	}
+
+	return rows, nil
+}
+
+// uniqueMiddleNames rejects the rows if the matches have different middle names
+func uniqueMiddleNames(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error) {
+	unique := map[string]string{}
+	for _, row := range rows {
+		// the unique doesn't need to be based on the full names
+		// ES matching is already doing the FN matching so here all we have to do is
+		// to check the middle name and fn1 to be sure it is unique for our needs
+		key := fmt.Sprintf("%s%s", strutils.FirstChar(row.Fn), row.Mn)
+		unique[key] = key
+	}
+
+	if len(unique) > 1 {
+		// if we have non unique matches in this already risky matching
+		// we should not continue
+
+		// @todo:
+		// Frank G
+		// Frank G       // these to will be ok but not these ones:
+
+		// Frank G
+		// Frank George  // this should also be ok I believe
+		env.reject(ctx, "[OneMiddleNameSearch] There are more people like %s* %s\n", strutils.FirstChar(in.Fn), in.Ln)
+
+		return nil, nil
+	}
+
+	return rows, nil
+}
+
+// noMorePeopleLikeFullName rejects the rows if there are other people like Fn *Mn* Ln
+func noMorePeopleLikeFullName(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error) {
+	q, err := env.ES.BaseQuery(in.did(), in.Country, in.ExclIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	q.Filter(
+		elastic.NewMatchPhraseQuery("ln", in.Ln),
+		firstNames(in),
+	)
+
+	others, err := env.ES.ExecuteQuery(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(others) > 1 {
+		env.reject(ctx, "[OneMiddleNameSearch] There are more people with the same initials Fn *Mn* Ln (%v)\n", ids(others))
+
+		return nil, nil
+	}
+
+	return rows, nil
+}
+
+// firstNames matches the first name of the input or one of its nicknames; the people called by
+// a nickname are the same people for the uniqueness checks
+func firstNames(in Input) *elastic.BoolQuery {
+	q := elastic.NewBoolQuery().Should(elastic.NewMatchPhraseQuery("fn", in.Fn))
+
+	for _, nick := range in.nicknames {
+		q.Should(elastic.NewMatchPhraseQuery("fn", nick))
+	}
+
+	return q.MinimumShouldMatch("1")
+}
+
+// noPeopleWithMiddleName rejects the rows if there is anybody like Fn X Ln
+func noPeopleWithMiddleName(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error) {
+	q, err := env.ES.BaseQuery(in.did(), in.Country, in.ExclIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	q.Filter(
+		elastic.NewMatchPhraseQuery("ln", in.Ln),
+		firstNames(in),
+		elastic.NewBoolQuery().MustNot(elastic.NewTermQuery("mn", "")),
+	)
+
+	others, err := env.ES.ExecuteQuery(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(others) > 0 {
+		env.reject(ctx, "[OneMiddleNameSearch2] There are more people like %s * %s (%v)\n", in.Fn, in.Ln, ids(others))
+
+		return nil, nil
+	}
+
+	return rows, nil
+}
+
+// uniqueInOneKey rejects the rows if the person exists more than once in the OneKey db. The rejected
+// rows are reported as an ambiguity. With firstInitial only the first character of the first name is compared
+func uniqueInOneKey(firstInitial bool) Filter {
+	return func(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error) {
+		if env.Mongo == nil {
+			return rows, nil
+		}
+
+		fn := in.Fn
+		if firstInitial {
+			fn = strutils.FirstChar(fn)
+		}
+
+		// for security reason - double checking if the match is the only one in the DB
+		cluster, err := env.Mongo.OneKeyCluster(in.CustName, fn, in.Ln)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(cluster) > 0 {
+			env.reject(ctx, "[%s] %s %s exists in OneKey more than once (%v)\n", in.strategy, fn, in.Ln, cluster)
+
+			in.ambiguous(Ambiguity{
+				Did:       in.did(),
+				Signature: fn + " " + in.Ln,
+				Onekeys:   cluster,
+				KIDs:      ids(rows),
+			})
+
+			return nil, nil
+		}
+
+		return rows, nil
+	}
+}
+
+// sameCity returns the rows from the input's city; nothing if not in the city-aware mode
+func sameCity(in Input, rows []*modelsES.ExpertHit) (local []*modelsES.ExpertHit) {
+	if !in.cityAware || in.City == "" {
+		return nil
+	}
+
+	for _, row := range rows {
+		if names.Same(row.City, in.City) {
+			local = append(local, row)
+		}
+	}
+
+	return
+}
+
+func ids(rows []*modelsES.ExpertHit) (ids []int) {
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+
+	return
+}
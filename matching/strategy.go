@@ -0,0 +1,195 @@
+// Package matching holds the matching strategies shared by the CLI (cmd/matching)
+// and the REST service (cmd/restful) so both of them run exactly the same steps
+package matching
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/tomekwlod/okpii/aliases"
+	modelsES "github.com/tomekwlod/okpii/models/es"
+	modelsMongodb "github.com/tomekwlod/okpii/models/mongodb"
+)
+
+// Logger is satisfied by both the standard *log.Logger and the utils logger
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Env contains everything a strategy may need to search and to double check the candidates
+type Env struct {
+	ES modelsES.Repository
+
+	// Mongo is optional; without it the OneKey uniqueness checks are skipped (eg. REST service)
+	Mongo modelsMongodb.Repository
+
+	// Nicknames are the nicknames of the OneKey first names used by the post-filters and the score.
+	// Set the same dictionary to modelsES.DB.Nicknames for the searches
+	Nicknames *aliases.Dictionary
+
+	Logger Logger
+}
+
+func (e *Env) logf(format string, v ...interface{}) {
+	if e.Logger == nil {
+		log.Printf(format, v...)
+		return
+	}
+
+	e.Logger.Printf(format, v...)
+}
+
+// Input is a person we are looking the matches for
+type Input struct {
+	// CustName is the OneKey CUST_NAME, used to count the OneKey duplicates
+	CustName string
+
+	Fn      string
+	Mn      string
+	Ln      string
+	Country string
+	City    string
+
+	// Dids are the deployments to search in; all of them are searched with a single request per strategy.
+	// With no deployments the whole index is searched as one
+	Dids []int
+
+	// ExclIDs are the ES ids which shouldn't be returned (eg. the expert itself)
+	ExclIDs []string
+
+	// nicknames of Fn from Env.Nicknames; set by the pipeline
+	nicknames []string
+
+	// result collects the ambiguities found by the post-filters; set by the pipeline
+	result   *Result
+	strategy string
+
+	// cityAware is set by the pipeline, see Pipeline.CityAware
+	cityAware bool
+}
+
+// did returns the deployment of the input the post-filters work on; 0 means all of them
+func (in Input) did() int {
+	if len(in.Dids) != 1 {
+		return 0
+	}
+
+	return in.Dids[0]
+}
+
+// ambiguous records the candidates rejected because the person couldn't be told apart from the others
+func (in Input) ambiguous(a Ambiguity) {
+	if in.result == nil {
+		return
+	}
+
+	a.Strategy = in.strategy
+	in.result.Ambiguities = append(in.result.Ambiguities, a)
+}
+
+// Strategy is a single matching step
+type Strategy interface {
+	// Name is a short label used in the logs and in the results, eg. "simple"
+	Name() string
+
+	// Weight is the base confidence (0-1) of the matches found by the strategy
+	Weight() float64
+
+	// Precondition tells if the strategy makes sense for the given names at all
+	Precondition(fn, mn, ln string) bool
+
+	// Search executes the ES query and returns the rows per deployment
+	Search(ctx context.Context, env *Env, in Input) (map[int][]*modelsES.ExpertHit, error)
+
+	// PostFilter removes the candidates which are too risky to be matched. It is called
+	// per deployment; in.Dids contains only the deployment of the rows
+	PostFilter(ctx context.Context, env *Env, in Input, rows []*modelsES.ExpertHit) ([]*modelsES.ExpertHit, error)
+}
+
+// LowConfidence marks the matches of the strategy as low-confidence; they are never accepted
+// automatically, see Match.LowConfidence
+func LowConfidence(s Strategy) Strategy {
+	return lowConfidence{s}
+}
+
+type lowConfidence struct {
+	Strategy
+}
+
+func (lowConfidence) LowConfidence() bool {
+	return true
+}
+
+func isLowConfidence(s Strategy) bool {
+	l, ok := s.(interface {
+		LowConfidence() bool
+	})
+
+	return ok && l.LowConfidence()
+}
+
+// Registry keeps the strategies in the order they were registered
+type Registry struct {
+	names      []string
+	strategies map[string]Strategy
+}
+
+func NewRegistry() *Registry {
+	return &Registry{strategies: map[string]Strategy{}}
+}
+
+// Default returns a registry with all the built-in strategies in the default order
+func Default() *Registry {
+	r := NewRegistry()
+
+	for _, s := range builtin() {
+		if err := r.Register(s); err != nil {
+			panic(err)
+		}
+	}
+
+	return r
+}
+
+func (r *Registry) Register(s Strategy) error {
+	if _, ok := r.strategies[s.Name()]; ok {
+		return fmt.Errorf("Strategy %s already registered", s.Name())
+	}
+
+	r.names = append(r.names, s.Name())
+	r.strategies[s.Name()] = s
+
+	return nil
+}
+
+func (r *Registry) Get(name string) (s Strategy, ok bool) {
+	s, ok = r.strategies[name]
+
+	return
+}
+
+// Names returns the names of the registered strategies in the registration order
+func (r *Registry) Names() []string {
+	return append([]string{}, r.names...)
+}
+
+// Pipeline returns the pipeline of the given strategies; with no names all of them are used
+func (r *Registry) Pipeline(env *Env, names ...string) (*Pipeline, error) {
+	if len(names) == 0 {
+		names = r.names
+	}
+
+	p := &Pipeline{env: env}
+
+	for _, name := range names {
+		s, ok := r.strategies[name]
+		if !ok {
+			return nil, fmt.Errorf("Strategy %s not registered", name)
+		}
+
+		p.strategies = append(p.strategies, s)
+	}
+
+	return p, nil
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	elastic "gopkg.in/olivere/elastic.v6"
+)
+
+// IndexStats are the bulk processor statistics of IndexExperts
+type IndexStats struct {
+	Indexed  int64         `json:"indexed"`
+	Failed   int64         `json:"failed"`
+	Flushes  int64         `json:"flushes"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Add sums up the statistics of many IndexExperts calls
+func (s *IndexStats) Add(o IndexStats) {
+	s.Indexed += o.Indexed
+	s.Failed += o.Failed
+	s.Flushes += o.Flushes
+	s.Duration += o.Duration
+}
+
+func (s IndexStats) String() string {
+	return fmt.Sprintf("%d indexed, %d failed, %d flushes in %v", s.Indexed, s.Failed, s.Flushes, s.Duration)
+}
+
+// BulkFailure is an expert ES refused to index
+type BulkFailure struct {
+	ID     string `json:"id"`
+	Status int    `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// BulkError is returned by IndexExperts when some of the experts couldn't be indexed; the
+// other experts of the call are indexed
+type BulkError struct {
+	Failures []BulkFailure `json:"failures"`
+}
+
+func (e *BulkError) Error() string {
+	ids := []string{}
+	for i, f := range e.Failures {
+		if i == 10 {
+			ids = append(ids, "...")
+			break
+		}
+
+		ids = append(ids, f.ID)
+	}
+
+	reason := ""
+	if len(e.Failures) > 0 {
+		reason = e.Failures[0].Reason
+	}
+
+	return fmt.Sprintf("%d experts couldn't be indexed: %s (%s)", len(e.Failures), strings.Join(ids, ", "), reason)
+}
+
+// Add appends the failures of another bulk error
+func (e *BulkError) Add(o *BulkError) {
+	e.Failures = append(e.Failures, o.Failures...)
+}
+
+// bulkFailures collects the failed items from the After callback of the bulk processor; the
+// callback is called by many workers at once
+type bulkFailures struct {
+	mu       sync.Mutex
+	failures []BulkFailure
+}
+
+func (b *bulkFailures) after(executionID int64, requests []elastic.BulkableRequest, response *elastic.BulkResponse, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err != nil {
+		// the whole commit failed
+		for _, r := range requests {
+			b.failures = append(b.failures, BulkFailure{ID: requestID(r), Reason: err.Error()})
+		}
+
+		return
+	}
+
+	if response == nil {
+		return
+	}
+
+	for _, item := range response.Failed() {
+		f := BulkFailure{ID: item.Id, Status: item.Status}
+		if item.Error != nil {
+			f.Reason = item.Error.Type + ": " + item.Error.Reason
+		}
+
+		b.failures = append(b.failures, f)
+	}
+}
+
+func (b *bulkFailures) err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.failures) == 0 {
+		return nil
+	}
+
+	return &BulkError{Failures: b.failures}
+}
+
+// requestID reads the document id from the action line of the bulk request
+func requestID(r elastic.BulkableRequest) string {
+	lines, err := r.Source()
+	if err != nil || len(lines) == 0 {
+		return ""
+	}
+
+	var action map[string]struct {
+		ID string `json:"_id"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &action); err != nil {
+		return ""
+	}
+
+	for _, meta := range action {
+		return meta.ID
+	}
+
+	return ""
+}
@@ -7,43 +7,58 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path"
 	"strconv"
+	"time"
 
+	"github.com/tomekwlod/okpii/aliases"
 	"github.com/tomekwlod/okpii/models"
 	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
-	"github.com/tomekwlod/utils"
 	elastic "gopkg.in/olivere/elastic.v6"
 )
 
-const mappingfn = "mapping.json"
-
 type Repository interface {
-	ExecuteQuery(q *elastic.BoolQuery) ([]map[string]interface{}, error)
-	Count(did int) int
-	FindOne(id, did int, ln string) (models.Expert, error)
-	MarkAsDeleted(id string) (err error)
-	UpdatePartially(id string, exp models.Expert) (err error)
-
-	// searches
+	ExecuteQuery(ctx context.Context, q *elastic.BoolQuery) ([]*ExpertHit, error)
+	Count(ctx context.Context, did int) (int, error)
+	FindOne(ctx context.Context, id, did int, ln string) (models.Expert, error)
+	MarkAsDeleted(ctx context.Context, id string) (err error)
+	ExpertIDs(ctx context.Context, did int) ([]int, error)
+	UpdatePartially(ctx context.Context, id string, exp models.Expert) (err error)
+	MappingDiff(ctx context.Context) (*MappingDiff, error)
+
+	// searches; every search runs once for all the deployments and returns the rows per deployment
 	BaseQuery(did int, country string, exclIDs []string) (*elastic.BoolQuery, error)
-	SimpleSearch(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{}
-	ForeignSearch(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{}
-	ShortSearch(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{}
-	NoMiddleNameSearch(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{}
-	OneMiddleNameSearch(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{}
-	OneMiddleNameSearch2(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{}
-	MadnessSearch(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{}
-	ThreeInitialsSearch(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{}
-	TestSearch(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{}
-
-	// index
-	RemoveData(did int) (int64, error)
-	IndexExperts(experts []*modelsMysql.Experts, batchInsert int) error
+	SimpleSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+	ForeignSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+	ShortSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+	NoMiddleNameSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+	OneMiddleNameSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+	OneMiddleNameSearch2(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+	MadnessSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+	ThreeInitialsSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+	PhoneticSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+	FuzzySearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+	TestSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error)
+
+	// index; "" as the index means the experts alias
+	RemoveData(ctx context.Context, did int) (int64, error)
+	IndexExperts(ctx context.Context, index string, experts []*modelsMysql.Experts, batchInsert int) (IndexStats, error)
+
+	// versions of the index behind the experts alias
+	CreateVersion(ctx context.Context) (string, error)
+	CurrentVersion(ctx context.Context) (string, error)
+	Versions(ctx context.Context) ([]string, error)
+	CopyDeployments(ctx context.Context, from, to string, exclDids []int) (int64, error)
+	CountVersion(ctx context.Context, index string, did int) (int64, error)
+	SwapAlias(ctx context.Context, from, to string) error
+	DeleteVersion(ctx context.Context, index string) error
 }
 
 type DB struct {
 	*elastic.Client
+
+	// Nicknames make the searches look for the nicknames of the first name too (Bill -> William);
+	// the experts' side is covered by the dump merging the dictionary into the aliases
+	Nicknames *aliases.Dictionary
 }
 
 type esConfig struct {
@@ -71,15 +86,15 @@ func ESClient() (*DB, error) {
 		return nil, err
 	}
 
-	// Create mapping
-	err = createIndex(db, "experts")
+	// Create the first version with the mapping if there is nothing to search yet
+	err = ensureAlias(context.Background(), db)
 	if err != nil {
 		return nil, err
 	}
 
 	fmt.Printf("\nConnection to ElasticServer established %s:%s\n", host, port)
 
-	return &DB{db}, nil
+	return &DB{Client: db}, nil
 }
 
 func newESClient(ec esConfig) (client *elastic.Client, err error) {
@@ -117,9 +132,10 @@ func newESClient(ec esConfig) (client *elastic.Client, err error) {
 	return
 }
 
-func createIndex(client *elastic.Client, index string) (err error) {
-	// Use the IndexExists service to check if a specified index exists.
-	exists, err := client.IndexExists(index).Do(context.Background())
+// ensureAlias creates the first version of the experts index behind the alias on an empty cluster
+func ensureAlias(ctx context.Context, client *elastic.Client) (err error) {
+	// Use the IndexExists service to check if a specified index (or alias) exists.
+	exists, err := client.IndexExists(alias).Do(ctx)
 	if err != nil {
 		return
 	}
@@ -130,21 +146,24 @@ func createIndex(client *elastic.Client, index string) (err error) {
 
 	fmt.Println("No mapping found. Creating one")
 
-	if os.Getenv("STATICPATH") == "" {
-		// in prod mode (with the docker) the STATICPATH won't be empty
-
-		// in dev mode set the default static path
-		os.Setenv("STATICPATH", "../../data/static")
+	index := versionName(time.Now())
+	if err = createIndex(ctx, client, index); err != nil {
+		return
 	}
-	filename := path.Join(os.Getenv("STATICPATH"), mappingfn)
 
-	// Create a new index
-	file, err := utils.ReadWholeFile(filename)
+	_, err = client.Alias().Add(index, alias).Do(ctx)
+
+	return
+}
+
+// createIndex creates the index with the mapping from the binary (see mapping.go)
+func createIndex(ctx context.Context, client *elastic.Client, index string) (err error) {
+	body, err := mappingBody()
 	if err != nil {
 		return
 	}
 
-	ic, err := client.CreateIndex(index).Body(string(file)).Do(context.Background())
+	ic, err := client.CreateIndex(index).Body(string(body)).Do(ctx)
 	if err != nil {
 		return
 	}
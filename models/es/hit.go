@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+
+	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
+	elastic "gopkg.in/olivere/elastic.v6"
+)
+
+// ExpertHit is a single expert found in ES; the indexed fields plus the hit details
+type ExpertHit struct {
+	modelsMysql.Experts
+
+	// Score is the ES _score of the hit
+	Score float64 `json:"_score"`
+	DocID string  `json:"_id"`
+
+	// Strategy is the name of the matching strategy which found the expert (set by the matching)
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// decodeHit decodes the hit's source and attaches the hit details to it
+func decodeHit(hit *elastic.SearchHit) (*ExpertHit, error) {
+	e := &ExpertHit{DocID: hit.Id}
+
+	if hit.Source != nil {
+		if err := json.Unmarshal(*hit.Source, &e.Experts); err != nil {
+			return nil, err
+		}
+	}
+
+	if hit.Score != nil {
+		e.Score = *hit.Score
+	}
+
+	return e, nil
+}
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+/*
+The mapping of the experts index lives in the binary: the version 1 (the mapping the index was created
+with before the versioning) plus the migrations made since then. Every new version of the index is created
+with it. Add the new fields as a new entry of migrations and run `mapping apply` (cmd/mapping) to migrate
+the live index. The properties are written the way ES returns them (no defaults) so they can be compared
+with the live index; the number of the replicas is left to the cluster default
+*/
+const mappingV1 = `{
+  "settings": {
+    "number_of_shards": 1,
+    "analysis": {
+      "normalizer": {
+        "lowercase": {
+          "type": "custom",
+          "filter": ["lowercase"]
+        },
+        "folded": {
+          "type": "custom",
+          "filter": ["lowercase", "asciifolding"]
+        },
+        "german": {
+          "type": "custom",
+          "filter": ["lowercase", "german_normalization", "asciifolding"]
+        }
+      },
+      "analyzer": {
+        "german": {
+          "tokenizer": "standard",
+          "filter": ["lowercase", "german_normalization", "asciifolding"]
+        }
+      }
+    }
+  },
+  "mappings": {
+    "data": {
+      "properties": {
+        "id": {"type": "integer"},
+        "did": {"type": "integer"},
+        "deleted": {"type": "integer"},
+        "npi": {"type": "integer"},
+        "ttid": {"type": "integer"},
+        "position": {"type": "integer"},
+        "name": {"type": "text"},
+        "nameKeyword": {
+          "type": "keyword",
+          "fields": {
+            "german": {"type": "keyword", "normalizer": "german"}
+          }
+        },
+        "nameKeywordSquash": {"type": "keyword", "normalizer": "lowercase"},
+        "nameKeywordRaw": {"type": "keyword", "normalizer": "folded"},
+        "fn": {"type": "keyword"},
+        "mn": {"type": "keyword"},
+        "ln": {
+          "type": "text",
+          "fields": {
+            "german": {"type": "text", "analyzer": "german"}
+          }
+        },
+        "fnDash": {"type": "boolean"},
+        "fnDot": {"type": "boolean"},
+        "country": {"type": "keyword"},
+        "city": {"type": "text"},
+        "aliases": {"type": "text"}
+      }
+    }
+  }
+}`
+
+// migrations are the properties added to the `data` type on top of mappingV1; the entry i makes the version i+2
+var migrations = []string{
+	// 2: the phonetic strategy
+	`{
+  "fnPhonetic": {"type": "keyword"},
+  "lnPhonetic": {"type": "keyword"},
+  "lnTranslit": {"type": "keyword"}
+}`,
+}
+
+// mappingVersion is the version of the mapping in the binary
+var mappingVersion = 1 + len(migrations)
+
+// mappingBody returns the body the new versions of the index are created with: mappingV1 with the
+// migrations and the version in _meta
+func mappingBody() (body []byte, err error) {
+	var m map[string]interface{}
+	if err = json.Unmarshal([]byte(mappingV1), &m); err != nil {
+		return
+	}
+
+	mappings, _ := m["mappings"].(map[string]interface{})
+	data, _ := mappings["data"].(map[string]interface{})
+	properties, _ := data["properties"].(map[string]interface{})
+	if properties == nil {
+		return nil, fmt.Errorf("Mapping has no properties of the data type")
+	}
+
+	for _, migration := range migrations {
+		var added map[string]interface{}
+		if err = json.Unmarshal([]byte(migration), &added); err != nil {
+			return
+		}
+
+		for field, property := range added {
+			properties[field] = property
+		}
+	}
+
+	data["_meta"] = map[string]interface{}{"version": mappingVersion}
+
+	return json.Marshal(m)
+}
+
+// MappingDiff compares the mapping in the binary with the mapping of the live index
+type MappingDiff struct {
+	Index string `json:"index"`
+
+	// Version is the version of the mapping in the binary, LiveVersion the one of the live index
+	// (0 for an index created before the versioning)
+	Version     int `json:"version"`
+	LiveVersion int `json:"liveVersion"`
+
+	// field paths, eg. "ln.fields.german"
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// Drift tells if the live index has to be migrated
+func (d *MappingDiff) Drift() bool {
+	return d.Version != d.LiveVersion || len(d.Added)+len(d.Removed)+len(d.Changed) > 0
+}
+
+type typeMapping struct {
+	Meta struct {
+		Version int `json:"version"`
+	} `json:"_meta"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// localMapping returns the mapping of the `data` type from the binary
+func localMapping() (m typeMapping, err error) {
+	b, err := mappingBody()
+	if err != nil {
+		return
+	}
+
+	var body struct {
+		Mappings map[string]typeMapping `json:"mappings"`
+	}
+
+	if err = json.Unmarshal(b, &body); err != nil {
+		return
+	}
+
+	return body.Mappings["data"], nil
+}
+
+// MappingDiff compares the mapping in the binary with the one of the index behind the experts alias
+func (db *DB) MappingDiff(ctx context.Context) (diff *MappingDiff, err error) {
+	local, err := localMapping()
+	if err != nil {
+		return
+	}
+
+	current, err := db.CurrentVersion(ctx)
+	if err != nil {
+		return
+	}
+	if current == "" {
+		return nil, fmt.Errorf("No %s index found", alias)
+	}
+
+	res, err := db.GetMapping().Index(current).Type("data").Do(ctx)
+	if err != nil {
+		return
+	}
+
+	// {"<index>": {"mappings": {"data": {...}}}}; the round trip decodes it into typeMapping
+	var live struct {
+		Mappings map[string]typeMapping `json:"mappings"`
+	}
+
+	raw, err := json.Marshal(res[current])
+	if err != nil {
+		return
+	}
+	if err = json.Unmarshal(raw, &live); err != nil {
+		return
+	}
+
+	lm := live.Mappings["data"]
+	diff = &MappingDiff{Index: current, Version: local.Meta.Version, LiveVersion: lm.Meta.Version}
+	compareProperties("", local.Properties, lm.Properties, diff)
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+
+	return
+}
+
+// compareProperties walks both mappings; a field is changed when anything but its sub-fields differs
+func compareProperties(prefix string, local, live map[string]interface{}, diff *MappingDiff) {
+	for name, l := range local {
+		path := prefix + name
+
+		r, ok := live[name]
+		if !ok {
+			diff.Added = append(diff.Added, path)
+			continue
+		}
+
+		lf, _ := l.(map[string]interface{})
+		rf, _ := r.(map[string]interface{})
+
+		if !reflect.DeepEqual(without(lf, "fields"), without(rf, "fields")) {
+			diff.Changed = append(diff.Changed, fmt.Sprintf("%s: %s -> %s", path, short(without(rf, "fields")), short(without(lf, "fields"))))
+		}
+
+		lsub, _ := lf["fields"].(map[string]interface{})
+		rsub, _ := rf["fields"].(map[string]interface{})
+		compareProperties(path+".fields.", lsub, rsub, diff)
+	}
+
+	for name := range live {
+		if _, ok := local[name]; !ok {
+			diff.Removed = append(diff.Removed, prefix+name)
+		}
+	}
+}
+
+func without(m map[string]interface{}, key string) map[string]interface{} {
+	c := map[string]interface{}{}
+	for k, v := range m {
+		if k != key {
+			c[k] = v
+		}
+	}
+
+	return c
+}
+
+func short(m map[string]interface{}) string {
+	b, _ := json.Marshal(m)
+
+	return string(b)
+}
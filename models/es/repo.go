@@ -4,60 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
 	"text/scanner"
+	"time"
 	"unicode"
 
+	countries "github.com/tomekwlod/okpii/country"
 	"github.com/tomekwlod/okpii/models"
 	modelsMysql "github.com/tomekwlod/okpii/models/mysql"
+	"github.com/tomekwlod/okpii/names"
 	strutils "github.com/tomekwlod/utils/strings"
 	elastic "gopkg.in/olivere/elastic.v6"
 )
 
-var countryCodes = map[string]string{
-	// "AND": "Andorra",
-	// "AUS": "Australia",
-	"AUT": "Austria",
-	"BEL": "Belgium",
-	"CHE": "Switzerland", //"Seychelles"
-	// "CZE": "Czech Republic",
-	"DEU": "Germany",
-	"DNK": "Denmark",
-	"ESP": "Spain",
-	// "EST": "Estonia",
-	"FIN": "Finland",
-	"FRA": "France",
-	// "FRO": "Faroe Islands",
-	"GBR": "United Kingdom",
-	// "GLP": "Guadeloupe",
-	// "GRL": "Greenland",
-	// "GUF": "French Guiana",
-	// "HRV": "Croatia",
-	// "HUN": "Hungary",
-	"IRL": "Ireland",
-	"ITA": "Italy",
-	// "LTU": "Lithuania",
-	// "LUX": "Luxembourg",
-	// "LVA": "Latvia",
-	// "MCO": "Monaco",
-	// "MTQ": "Martinique",
-	// "MYT": "Mayotte",
-	// "NCL": "New Caledonia",
-	"NLD": "Netherlands",
-	"NOR": "Norway",
-	// "NZL": "New Zealand",
-	// "POL": "Poland",
-	"PRT": "Portugal",
-	// "PYF": "French Polynesia",
-	// "REU": "Reunion",
-	// "SPM": "Saint Pierre and Miquelon",
-	// "SVK": "Slovakia",
-	// "SVN": "Slovenia",
-	"SWE": "Sweden",
-	// "TUR": "Turkey",
-	"WLF": "Wallis and Futuna",
-}
+// cityBoost puts the candidates from the OneKey city on top of the hits
+const cityBoost = 10
 
 func baseQuery(did int, country string, exclIDs []string) (*elastic.BoolQuery, error) {
 
@@ -70,12 +33,13 @@ func baseQuery(did int, country string, exclIDs []string) (*elastic.BoolQuery, e
 	}
 
 	if country != "" {
-		if val, ok := countryCodes[country]; ok {
-			// if country provided, use it, otherwise ignore the country at all
-			q.Filter(elastic.NewMatchPhraseQuery("country", val))
-		} else {
-			return nil, fmt.Errorf("Country code %s not defined", country)
+		// if country provided (code or name), use it, otherwise ignore the country at all
+		name, err := countries.Name(country)
+		if err != nil {
+			return nil, err
 		}
+
+		q.Filter(elastic.NewMatchPhraseQuery("country", name))
 	}
 
 	// main id to be excluded if passed
@@ -107,41 +71,120 @@ func lastNameQuery(q *elastic.BoolQuery, ln string) *elastic.BoolQuery {
 	return q
 }
 
-// BaseQuery just returns the base query so you can use it for your specifin needs
+// BaseQuery just returns the base query so you can use it for your specifin needs; it doesn't call ES so it takes no context
 func (db *DB) BaseQuery(did int, country string, exclIDs []string) (*elastic.BoolQuery, error) {
 	return baseQuery(did, country, exclIDs)
 }
-func (db *DB) ExecuteQuery(q *elastic.BoolQuery) (result []map[string]interface{}, err error) {
+func (db *DB) ExecuteQuery(ctx context.Context, q *elastic.BoolQuery) (result []*ExpertHit, err error) {
 	nss := elastic.NewSearchSource().Query(q)
 
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(100).Do(context.Background())
+	trace := traceFrom(ctx)
+	if trace != nil {
+		nss = nss.Explain(true)
+	}
+
+	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(100).Do(ctx)
 	if err != nil {
 		return
 	}
 
+	if trace != nil {
+		trace.add(0, nss, searchResult.Hits)
+	}
+
 	if searchResult.Hits.TotalHits > 0 {
 		for _, hit := range searchResult.Hits.Hits {
-			var row map[string]interface{}
-
-			err := json.Unmarshal(*hit.Source, &row)
+			e, err := decodeHit(hit)
 			if err != nil {
-				panic(err)
+				return nil, err
 			}
 
-			result = append(result, row)
+			result = append(result, e)
 		}
 	}
 
 	return
 }
 
-func (db *DB) SimpleSearch(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{} {
-	result := []map[string]interface{}{}
+// multiSearch runs the query once per deployment in a single msearch request, so the hits (and their
+// totals) are still per deployment. With no deployments the query runs across all of them under did 0.
+// If the city is given the candidates from the same city are boosted (but not required)
+func (db *DB) multiSearch(ctx context.Context, q *elastic.BoolQuery, dids []int, city string, size int) (map[int]*elastic.SearchHits, error) {
+	if len(dids) == 0 {
+		dids = []int{0}
+	}
+
+	if city != "" {
+		q = elastic.NewBoolQuery().Must(q).Should(elastic.NewMatchQuery("city", city).Boost(cityBoost))
+	}
+
+	trace := traceFrom(ctx)
+
+	ms := db.MultiSearch()
+	sources := []*elastic.SearchSource{}
+	for _, did := range dids {
+		var dq elastic.Query = q
+		if did != 0 {
+			dq = elastic.NewBoolQuery().Must(q).Filter(elastic.NewMatchPhraseQuery("did", did))
+		}
+
+		nss := elastic.NewSearchSource().Query(dq).From(0).Size(size)
+		if trace != nil {
+			nss = nss.Explain(true)
+		}
 
-	q, err := baseQuery(did, country, exclIDs)
+		sources = append(sources, nss)
+		ms.Add(elastic.NewSearchRequest().Index("experts").Type("data").SearchSource(nss))
+	}
 
+	res, err := ms.Do(ctx)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+
+	hits := map[int]*elastic.SearchHits{}
+	for i, r := range res.Responses {
+		if r.Error != nil {
+			return nil, fmt.Errorf("Search failed for did:%d: %s", dids[i], r.Error.Reason)
+		}
+
+		hits[dids[i]] = r.Hits
+
+		if trace != nil {
+			trace.add(dids[i], sources[i], r.Hits)
+		}
+	}
+
+	return hits, nil
+}
+
+// expertsPerDeployment decodes the hits of every deployment; the deployments with no hits are skipped
+func expertsPerDeployment(hits map[int]*elastic.SearchHits) (map[int][]*ExpertHit, error) {
+	result := map[int][]*ExpertHit{}
+
+	for did, h := range hits {
+		if h == nil || h.TotalHits == 0 {
+			continue
+		}
+
+		for _, hit := range h.Hits {
+			e, err := decodeHit(hit)
+			if err != nil {
+				return nil, err
+			}
+
+			result[did] = append(result[did], e)
+		}
+	}
+
+	return result, nil
+}
+
+func (db *DB) SimpleSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
+	q, err := baseQuery(0, country, exclIDs)
+
+	if err != nil {
+		return nil, err
 	}
 
 	mnstr := " "
@@ -167,6 +210,11 @@ func (db *DB) SimpleSearch(fn, mn, ln, country, city string, did int, exclIDs []
 		q.Should(elastic.NewTermQuery("nameKeywordRaw", nameRaw))
 	}
 
+	// Bill Mark Smith -> William Mark Smith
+	for _, nick := range db.Nicknames.Nicknames(fn) {
+		q.Should(elastic.NewTermQuery("nameKeyword", nick+mnstr+ln))
+	}
+
 	if name1 != name {
 		// John M Smith <- with ASCII-folding
 		q.Should(elastic.NewTermQuery("nameKeyword", name1))
@@ -180,38 +228,18 @@ func (db *DB) SimpleSearch(fn, mn, ln, country, city string, did int, exclIDs []
 
 	q.MinimumShouldMatch("1")
 
-	// this is the best if we want to print the query for the test purposes
-	nss := elastic.NewSearchSource().Query(q)
-
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(context.Background())
+	hits, err := db.multiSearch(ctx, q, dids, city, 10)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	if searchResult.Hits.TotalHits == 0 {
-		return nil
-	}
-
-	for _, hit := range searchResult.Hits.Hits {
-		var row map[string]interface{}
-
-		err := json.Unmarshal(*hit.Source, &row)
-		if err != nil {
-			panic(err)
-		}
-
-		result = append(result, row)
-	}
-
-	return result
+	return expertsPerDeployment(hits)
 }
 
-func (db *DB) ForeignSearch(fn, mn, ln, country, city string, did int, exclIDs []string) []map[string]interface{} {
-	result := []map[string]interface{}{}
-
-	q, err := baseQuery(did, country, exclIDs)
+func (db *DB) ForeignSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
+	q, err := baseQuery(0, country, exclIDs)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	mnstr := " "
@@ -222,43 +250,28 @@ func (db *DB) ForeignSearch(fn, mn, ln, country, city string, did int, exclIDs [
 	name := fn + mnstr + ln
 
 	q.Should(elastic.NewTermQuery("nameKeyword.german", name))
+	for _, nick := range db.Nicknames.Nicknames(fn) {
+		q.Should(elastic.NewTermQuery("nameKeyword.german", nick+mnstr+ln))
+	}
 	q.MinimumShouldMatch("1")
 
-	// this is the best if we want to print the query for the test purposes
-	nss := elastic.NewSearchSource().Query(q)
-
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(context.Background())
+	hits, err := db.multiSearch(ctx, q, dids, city, 10)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	if searchResult.Hits.TotalHits == 0 {
-		return nil
-	}
-
-	for _, hit := range searchResult.Hits.Hits {
-		var row map[string]interface{}
-
-		err := json.Unmarshal(*hit.Source, &row)
-		if err != nil {
-			panic(err)
-		}
-
-		result = append(result, row)
-	}
-
-	return result
+	return expertsPerDeployment(hits)
 }
 
-func (db *DB) ShortSearch(fn, mn, ln, country, city string, did int, exclIDs []string) (result []map[string]interface{}) {
+func (db *DB) ShortSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
 	if mn == "" {
 		// this case is only for the names with MN included
-		return nil
+		return nil, nil
 	}
 
-	q, err := baseQuery(did, country, exclIDs)
+	q, err := baseQuery(0, country, exclIDs)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	// adding LN to a query
@@ -275,7 +288,7 @@ func (db *DB) ShortSearch(fn, mn, ln, country, city string, did int, exclIDs []s
 		// J E Cortes
 		// or
 		// J Cortes
-		return nil
+		return nil, nil
 	}
 
 	mn1q := elastic.NewBoolQuery()
@@ -292,45 +305,22 @@ func (db *DB) ShortSearch(fn, mn, ln, country, city string, did int, exclIDs []s
 	}
 	mn1q.MinimumShouldMatch("1")
 
-	fn1q := elastic.NewBoolQuery()
-	fn1q.Should(elastic.NewMatchPhraseQuery("fn", fn))
-	fn1q.Should(elastic.NewMatchPhraseQuery("aliases", fn))
+	fn1q := db.firstNameQuery(fn)
 	if fnl > 1 {
 		fn1q.Should(elastic.NewMatchPhraseQuery("fn", strutils.FirstChar(fn)))
 	}
-	fn1q.MinimumShouldMatch("1")
 
 	q.Must(mn1q, fn1q)
 
-	nss := elastic.NewSearchSource().Query(q)
-
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(context.Background())
+	hits, err := db.multiSearch(ctx, q, dids, city, 10)
 	if err != nil {
-		panic(err)
-	}
-
-	if searchResult.Hits.TotalHits == 0 {
-		// fmt.Printf("[%s] %s %s %s \t\t ====> Not found\n", id, fn, mn, ln)
-		return nil
+		return nil, err
 	}
 
-	for _, hit := range searchResult.Hits.Hits {
-		var row map[string]interface{}
-
-		err := json.Unmarshal(*hit.Source, &row)
-		if err != nil {
-			panic(err)
-		}
-
-		result = append(result, row)
-
-		// fmt.Printf("[%s] %s %s %s {%s}\t\t ====> \t [%s] %s, {%s} npi: %v, ttid: %v\n", id, fn, mn, ln, city, hit.Id, row["name"], row["city"], row["npi"], row["ttid"])
-	}
-
-	return
+	return expertsPerDeployment(hits)
 }
 
-func (db *DB) MadnessSearch(fn, mn, ln, country, city string, did int, exclIDs []string) (result []map[string]interface{}) {
+func (db *DB) MadnessSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
 	if strutils.Length(fn) != 1 {
 
 		// S<- Rule
@@ -347,12 +337,12 @@ func (db *DB) MadnessSearch(fn, mn, ln, country, city string, did int, exclIDs [
 		// Simon A J Rule
 
 		// this case is only for the names with MN included
-		return nil
+		return nil, nil
 	}
 
-	q, err := baseQuery(did, country, exclIDs)
+	q, err := baseQuery(0, country, exclIDs)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	// adding LN to a query
@@ -365,7 +355,7 @@ func (db *DB) MadnessSearch(fn, mn, ln, country, city string, did int, exclIDs [
 	mnl := strutils.Length(mn)
 
 	if fnl != 1 {
-		return nil
+		return nil, nil
 	}
 
 	fnq := elastic.NewBoolQuery()
@@ -389,37 +379,16 @@ func (db *DB) MadnessSearch(fn, mn, ln, country, city string, did int, exclIDs [
 		q.Must(fnq)
 	}
 
-	nss := elastic.NewSearchSource().Query(q)
-
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(context.Background())
+	hits, err := db.multiSearch(ctx, q, dids, city, 10)
 	if err != nil {
-		panic(err)
-	}
-
-	if searchResult.Hits.TotalHits == 0 {
-		return nil
-	}
-	if searchResult.Hits.TotalHits > 1 {
-		return nil
-	}
-
-	for _, hit := range searchResult.Hits.Hits {
-		var row map[string]interface{}
-
-		err := json.Unmarshal(*hit.Source, &row)
-		if err != nil {
-			panic(err)
-		}
-
-		result = append(result, row)
-
-		// fmt.Printf("[%s] %s %s %s {%s}\t\t ====> \t [%s] %s, {%s} npi: %v, ttid: %v\n", id, fn, mn, ln, city, hit.Id, row["name"], row["city"], row["npi"], row["ttid"])
+		return nil, err
 	}
 
-	return
+	// more than one hit is disambiguated (or rejected) by the matching post-filters
+	return expertsPerDeployment(hits)
 }
 
-func (db *DB) NoMiddleNameSearch(fn, mn, ln, country, city string, did int, exclIDs []string) (result []map[string]interface{}) {
+func (db *DB) NoMiddleNameSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
 	// this case is only for the names with NO MN on both sides!!
 	//
 	// EXPLANATION
@@ -441,12 +410,12 @@ func (db *DB) NoMiddleNameSearch(fn, mn, ln, country, city string, did int, excl
 		// 0.   R  Dittrich {}
 		//
 		// We want to match this only if R* Dittrich exist in OneKey Db only once!
-		return nil
+		return nil, nil
 	}
 
-	q, err := baseQuery(did, country, exclIDs)
+	q, err := baseQuery(0, country, exclIDs)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	// adding LN to a query
@@ -462,36 +431,16 @@ func (db *DB) NoMiddleNameSearch(fn, mn, ln, country, city string, did int, excl
 	} else {
 		q.Must(elastic.NewPrefixQuery("fn", strutils.FirstChar(fn)))
 	}
-	nss := elastic.NewSearchSource().Query(q)
-
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(context.Background())
+	hits, err := db.multiSearch(ctx, q, dids, city, 10)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	if searchResult.Hits.TotalHits == 0 {
-		return nil
-	}
-
-	if searchResult.Hits.TotalHits > 1 {
-		return nil
-	}
-
-	for _, hit := range searchResult.Hits.Hits {
-		var row map[string]interface{}
-
-		err := json.Unmarshal(*hit.Source, &row)
-		if err != nil {
-			panic(err)
-		}
-
-		result = append(result, row)
-	}
-
-	return
+	// more than one hit is disambiguated (or rejected) by the matching post-filters
+	return expertsPerDeployment(hits)
 }
 
-func (db *DB) OneMiddleNameSearch(fn, mn, ln, country, city string, did int, exclIDs []string) (result []map[string]interface{}) {
+func (db *DB) OneMiddleNameSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
 	// this case is only for the names with NO MN incomming
 	//
 	// EXPLANATION
@@ -507,51 +456,28 @@ func (db *DB) OneMiddleNameSearch(fn, mn, ln, country, city string, did int, exc
 		// 0.Ralf F. Dittrich {}
 		//
 		// We want to match this only if Ralf * Dittrich exist in OneKey Db only once!
-		return nil
+		return nil, nil
 	}
 
-	q, err := baseQuery(did, country, exclIDs)
+	q, err := baseQuery(0, country, exclIDs)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	// adding LN to a query
 	q = lastNameQuery(q, ln)
 	q.MustNot(elastic.NewTermQuery("mn", ""))
+	q.Must(db.firstNameQuery(fn))
 
-	fnq := elastic.NewBoolQuery().Should(
-		elastic.NewMatchPhraseQuery("aliases", fn),
-		elastic.NewMatchPhraseQuery("fn", fn),
-	)
-	fnq.Should().MinimumShouldMatch("1")
-	q.Must(fnq)
-
-	nss := elastic.NewSearchSource().Query(q)
-
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(context.Background())
+	hits, err := db.multiSearch(ctx, q, dids, city, 10)
 	if err != nil {
-		panic(err)
-	}
-
-	if searchResult.Hits.TotalHits == 0 {
-		return nil
-	}
-
-	for _, hit := range searchResult.Hits.Hits {
-		var row map[string]interface{}
-
-		err := json.Unmarshal(*hit.Source, &row)
-		if err != nil {
-			panic(err)
-		}
-
-		result = append(result, row)
+		return nil, err
 	}
 
-	return
+	return expertsPerDeployment(hits)
 }
 
-func (db *DB) OneMiddleNameSearch2(fn, mn, ln, country, city string, did int, exclIDs []string) (result []map[string]interface{}) {
+func (db *DB) OneMiddleNameSearch2(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
 
 	// this case is only for the names WITH MN included
 	//
@@ -568,12 +494,12 @@ func (db *DB) OneMiddleNameSearch2(fn, mn, ln, country, city string, did int, ex
 		// 0.Ralf    Dittrich {}
 		//
 		// We want to match this only if Ralf * Dittrich exist in OneKey Db only once!
-		return nil
+		return nil, nil
 	}
 
-	q, err := baseQuery(did, country, exclIDs)
+	q, err := baseQuery(0, country, exclIDs)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	// adding LN to a query
@@ -582,41 +508,17 @@ func (db *DB) OneMiddleNameSearch2(fn, mn, ln, country, city string, did int, ex
 	q.Filter(
 		elastic.NewTermQuery("mn", ""),
 	)
+	q.Must(db.firstNameQuery(fn))
 
-	fnq := elastic.NewBoolQuery().Should(
-		elastic.NewMatchPhraseQuery("aliases", fn),
-		elastic.NewMatchPhraseQuery("fn", fn),
-	)
-	fnq.Should().MinimumShouldMatch("1")
-	q.Must(fnq)
-
-	nss := elastic.NewSearchSource().Query(q)
-
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(context.Background())
+	hits, err := db.multiSearch(ctx, q, dids, city, 10)
 	if err != nil {
-		panic(err)
-	}
-
-	if searchResult.Hits.TotalHits == 0 {
-		// fmt.Printf("[%s] %s %s %s \t\t ====> Not found\n", id, fn, mn, ln)
-		return nil
+		return nil, err
 	}
 
-	for _, hit := range searchResult.Hits.Hits {
-		var row map[string]interface{}
-
-		err := json.Unmarshal(*hit.Source, &row)
-		if err != nil {
-			panic(err)
-		}
-
-		result = append(result, row)
-	}
-
-	return
+	return expertsPerDeployment(hits)
 }
 
-func (db *DB) ThreeInitialsSearch(fn, mn, ln, country, city string, did int, exclIDs []string) (result []map[string]interface{}) {
+func (db *DB) ThreeInitialsSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
 	if mn != "" || len(fn) <= 1 {
 		// this case is only for the names with NO MN included
 		// also first name needs to be longer than 1 character
@@ -624,12 +526,12 @@ func (db *DB) ThreeInitialsSearch(fn, mn, ln, country, city string, did int, exc
 		// ---------------------------
 		// -> GJ     OSSENKOPPELE
 		// 0. Gert J OSSENKOPPELE
-		return nil
+		return nil, nil
 	}
 
-	q, err := baseQuery(did, country, exclIDs)
+	q, err := baseQuery(0, country, exclIDs)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
 	// adding LN to a query
@@ -639,50 +541,160 @@ func (db *DB) ThreeInitialsSearch(fn, mn, ln, country, city string, did int, exc
 		elastic.NewPrefixQuery("fn", strutils.FirstChar(fn)),
 	)
 
-	nss := elastic.NewSearchSource().Query(q)
-
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(context.Background())
+	hits, err := db.multiSearch(ctx, q, dids, city, 10)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	if searchResult.Hits.TotalHits == 0 {
-		// fmt.Printf("[%s] %s %s %s \t\t ====> Not found\n", id, fn, mn, ln)
-		return nil
+	result, err := expertsPerDeployment(hits)
+	if err != nil {
+		return nil, err
 	}
 
-	for _, hit := range searchResult.Hits.Hits {
-		var row map[string]interface{}
+	for did, experts := range result {
+		matched := []*ExpertHit{}
 
-		err := json.Unmarshal(*hit.Source, &row)
-		if err != nil {
-			panic(err)
-		}
+		for _, e := range experts {
+			// checking the initials
+			var initials []string
+			for _, char := range e.Fn + e.Mn {
+				if !unicode.IsLower(char) && char != ' ' {
+					var s string
+					s = scanner.TokenString(char)
+					s = s[1 : len(s)-1] // this removed the quotes around the string
 
-		// checking the initials
-		var initials []string
-		for _, char := range row["fn"].(string) + row["mn"].(string) {
-			if !unicode.IsLower(char) && char != ' ' {
-				var s string
-				s = scanner.TokenString(char)
-				s = s[1 : len(s)-1] // this removed the quotes around the string
+					initials = append(initials, s)
+				}
+			}
 
-				initials = append(initials, s)
+			if strings.Join(initials, "") == fn {
+				matched = append(matched, e)
 			}
 		}
 
-		if strings.Join(initials, "") == fn {
-			result = append(result, row)
+		if len(matched) == 0 {
+			delete(result, did)
+			continue
 		}
+
+		result[did] = matched
 	}
 
-	return
+	return result, nil
 }
 
-func (db *DB) TestSearch(fn, mn, ln, country, city string, did int, exclIDs []string) (result []map[string]interface{}) {
-	q, err := baseQuery(did, country, exclIDs)
+func (db *DB) PhoneticSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
+	// the last name sounds the same (Cologne phonetics) or is the same after the transliteration
+	//
+	// EXPLANATION
+	//
+	// For a given Hübel  Anna
+	// find        Huebel Anna  (transliterated)
+	//             Hubel  Anna  (same code: 015)
+	// and the first name has to be the same, one of the aliases or sound the same
+
+	lnCode := names.Cologne(ln)
+	fnCode := names.Cologne(fn)
+	if lnCode == "" || fnCode == "" {
+		return nil, nil
+	}
+
+	q, err := baseQuery(0, country, exclIDs)
 	if err != nil {
-		return nil
+		return nil, err
+	}
+
+	q.Must(elastic.NewBoolQuery().Should(
+		elastic.NewTermQuery("lnPhonetic", lnCode),
+		elastic.NewTermQuery("lnTranslit", names.Transliterate(ln)),
+	).MinimumShouldMatch("1"))
+
+	q.Must(db.firstNameQuery(fn).Should(elastic.NewTermQuery("fnPhonetic", fnCode)))
+
+	hits, err := db.multiSearch(ctx, q, dids, city, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	// more than one hit is disambiguated (or rejected) by the matching post-filters
+	return expertsPerDeployment(hits)
+}
+
+// firstNameQuery matches the first name itself, one of the expert's aliases or one of the nicknames of the first name
+func (db *DB) firstNameQuery(fn string) *elastic.BoolQuery {
+	q := elastic.NewBoolQuery().Should(
+		elastic.NewMatchPhraseQuery("fn", fn),
+		elastic.NewMatchPhraseQuery("aliases", fn),
+	)
+
+	for _, nick := range db.Nicknames.Nicknames(fn) {
+		q.Should(elastic.NewMatchPhraseQuery("fn", nick))
+	}
+
+	return q.MinimumShouldMatch("1")
+}
+
+// fuzzyEdits is the max edit distance of the last names in FuzzySearch for the given length;
+// the short names don't get any (too many other names are one letter away)
+func fuzzyEdits(ln string) int {
+	switch l := strutils.Length(ln); {
+	case l < 5:
+		return 0
+	case l < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (db *DB) FuzzySearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
+	// the last name with a typo; the first name (or the initials) has to be exactly the same,
+	// no nicknames nor prefixes since the last name is already a guess
+	//
+	// EXPLANATION
+	//
+	// For a given Anna   Hübel
+	// find        Anna   Hüebel
+	// or for      Anna M Hübel
+	// find        A    M Hüebl
+	// but not     Anne M Hüebl, Ann M Hüebl nor Anna Maria Hüebl (unless Anna Hüebl)
+
+	edits := fuzzyEdits(ln)
+	if edits == 0 || strings.ContainsAny(ln, " -") {
+		return nil, nil
+	}
+
+	q, err := baseQuery(0, country, exclIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first letter is never the typo; the exact last names are left to the other strategies
+	q.Must(elastic.NewFuzzyQuery("ln", strings.ToLower(ln)).Fuzziness(edits).PrefixLength(1).MaxExpansions(50).Transpositions(true))
+	q.MustNot(elastic.NewMatchPhraseQuery("ln", ln))
+
+	fnq := elastic.NewBoolQuery().Should(elastic.NewTermQuery("fn", fn))
+	if mn != "" {
+		fnq.Should(elastic.NewBoolQuery().Must(
+			elastic.NewTermQuery("fn", strutils.FirstChar(fn)),
+			elastic.NewTermQuery("mn", strutils.FirstChar(mn)),
+		))
+	}
+	q.Must(fnq.MinimumShouldMatch("1"))
+
+	hits, err := db.multiSearch(ctx, q, dids, city, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	// more than one hit is rejected by the matching post-filters
+	return expertsPerDeployment(hits)
+}
+
+func (db *DB) TestSearch(ctx context.Context, fn, mn, ln, country, city string, dids []int, exclIDs []string) (map[int][]*ExpertHit, error) {
+	q, err := baseQuery(0, country, exclIDs)
+	if err != nil {
+		return nil, err
 	}
 
 	// adding LN to a query
@@ -692,62 +704,59 @@ func (db *DB) TestSearch(fn, mn, ln, country, city string, did int, exclIDs []st
 		elastic.NewPrefixQuery("fn", strutils.FirstChar(fn)),
 	)
 
-	nss := elastic.NewSearchSource().Query(q)
-
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(200).Do(context.Background())
+	hits, err := db.multiSearch(ctx, q, dids, city, 200)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	if searchResult.Hits.TotalHits > 0 {
+	for did, h := range hits {
+		if h.TotalHits == 0 {
+			// fmt.Printf("{q%d} [%s] %s %s %s \t\t ====> Not found\n", i, id, fn, mn, ln)
+			continue
+		}
+
 		n := []string{}
 		ids := []string{}
 
-		for i, hit := range searchResult.Hits.Hits {
-			var row map[string]interface{}
-
-			err := json.Unmarshal(*hit.Source, &row)
+		for i, hit := range h.Hits {
+			e, err := decodeHit(hit)
 			if err != nil {
-				panic(err)
+				return nil, err
 			}
 
-			n = append(n, strconv.Itoa(i)+". "+row["fn"].(string)+" "+row["mn"].(string)+" "+row["ln"].(string)+" {"+row["city"].(string)+"}")
+			n = append(n, strconv.Itoa(i)+". "+e.Fn+" "+e.Mn+" "+e.Ln+" {"+e.City+"}")
 
-			kid64 := row["id"].(float64)
-			kid := int(kid64)
-			ids = append(ids, strconv.Itoa(kid))
+			ids = append(ids, strconv.Itoa(e.ID))
 		}
 
 		fmt.Printf("\n\n---------------------------\n->%s %s %s {%s} \t\t ====> Found [did:%d]: %s\n%s \n\n", fn, mn, ln, city, did, strings.Join(ids, ","), strings.Join(n, "\n"))
-	} else {
-		// fmt.Printf("{q%d} [%s] %s %s %s \t\t ====> Not found\n", i, id, fn, mn, ln)
 	}
 
-	return
+	return nil, nil
 }
 
-func (db *DB) Count(did int) (count int) {
+func (db *DB) Count(ctx context.Context, did int) (count int, err error) {
 	q, err := baseQuery(did, "", nil)
 	if err != nil {
-		panic(err)
+		return
 	}
 
 	nss := elastic.NewSearchSource().Query(q)
 
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(context.Background())
+	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(ctx)
 	if err != nil {
-		panic(err)
+		return
 	}
 
 	hits := searchResult.Hits.TotalHits
 
-	return int(hits)
+	return int(hits), nil
 }
 
-func (db *DB) FindOne(id, did int, ln string) (expert models.Expert, err error) {
+func (db *DB) FindOne(ctx context.Context, id, did int, ln string) (expert models.Expert, err error) {
 	q, err := baseQuery(did, "", nil)
 	if err != nil {
-		panic(err)
+		return
 	}
 
 	q.Must(elastic.NewMatchPhraseQuery("id", id))
@@ -755,9 +764,9 @@ func (db *DB) FindOne(id, did int, ln string) (expert models.Expert, err error)
 
 	nss := elastic.NewSearchSource().Query(q)
 
-	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(context.Background())
+	searchResult, err := db.Search().Index("experts").Type("data").SearchSource(nss).From(0).Size(10).Do(ctx)
 	if err != nil {
-		panic(err)
+		return
 	}
 
 	hits := searchResult.Hits.TotalHits
@@ -769,20 +778,19 @@ func (db *DB) FindOne(id, did int, ln string) (expert models.Expert, err error)
 	}
 
 	for _, hit := range searchResult.Hits.Hits {
-		err := json.Unmarshal(*hit.Source, &expert)
-		if err != nil {
-			panic(err)
+		if err = json.Unmarshal(*hit.Source, &expert); err != nil {
+			return
 		}
 	}
 
 	return
 }
 
-func (db *DB) RemoveData(did int) (deleted int64, err error) {
-	del, err := db.DeleteByQuery("experts").Query(elastic.NewMatchPhraseQuery("did", did)).Do(context.TODO())
+func (db *DB) RemoveData(ctx context.Context, did int) (deleted int64, err error) {
+	del, err := db.DeleteByQuery("experts").Query(elastic.NewMatchPhraseQuery("did", did)).Do(ctx)
 
 	// move below to a separate function
-	// deleteIndex, err := db.DeleteIndex("experts").Do(context.TODO())
+	// deleteIndex, err := db.DeleteIndex("experts").Do(ctx)
 
 	if err != nil {
 		return
@@ -795,8 +803,38 @@ func (db *DB) RemoveData(did int) (deleted int64, err error) {
 	return del.Deleted, nil
 }
 
-func (db *DB) MarkAsDeleted(id string) (err error) {
-	_, err = db.Update().Index("experts").Type("data").Id(id).Doc(map[string]int{"deleted": 1}).Do(context.TODO())
+// ExpertIDs returns the ids of the experts of the deployment which are not marked as deleted
+func (db *DB) ExpertIDs(ctx context.Context, did int) (ids []int, err error) {
+	q := elastic.NewBoolQuery().Filter(
+		elastic.NewMatchPhraseQuery("did", did),
+		elastic.NewMatchPhraseQuery("deleted", 0),
+	)
+
+	scroll := db.Scroll(alias).Type("data").Query(q).FetchSource(false).Size(5000)
+	defer scroll.Clear(context.Background())
+
+	for {
+		res, err := scroll.Do(ctx)
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hit := range res.Hits.Hits {
+			id, err := strconv.Atoi(hit.Id)
+			if err != nil {
+				return nil, err
+			}
+
+			ids = append(ids, id)
+		}
+	}
+}
+
+func (db *DB) MarkAsDeleted(ctx context.Context, id string) (err error) {
+	_, err = db.Update().Index("experts").Type("data").Id(id).Doc(map[string]int{"deleted": 1}).Do(ctx)
 
 	if err != nil {
 		return
@@ -804,8 +842,8 @@ func (db *DB) MarkAsDeleted(id string) (err error) {
 
 	return
 }
-func (db *DB) UpdatePartially(id string, exp models.Expert) (err error) {
-	_, err = db.Update().Index("experts").Type("data").Id(id).Doc(exp).Do(context.TODO())
+func (db *DB) UpdatePartially(ctx context.Context, id string, exp models.Expert) (err error) {
+	_, err = db.Update().Index("experts").Type("data").Id(id).Doc(exp).Do(ctx)
 
 	if err != nil {
 		return
@@ -814,56 +852,64 @@ func (db *DB) UpdatePartially(id string, exp models.Expert) (err error) {
 	return
 }
 
-func (db *DB) IndexExperts(experts []*modelsMysql.Experts, batchInsert int) (err error) {
+// IndexExperts indexes the experts in bulks. The experts ES refused are returned as a *BulkError
+// (the other ones are indexed anyway) together with the statistics of the bulk processor
+func (db *DB) IndexExperts(ctx context.Context, index string, experts []*modelsMysql.Experts, batchInsert int) (stats IndexStats, err error) {
+	if index == "" {
+		index = alias
+	}
 	if batchInsert == 0 {
 		batchInsert = 1000
 	}
 	fmt.Printf("Processing %d experts\n", len(experts))
 
+	t := time.Now()
+	failures := &bulkFailures{}
+
 	// move below to a separate function
 	p, err := db.BulkProcessor().Name("bdWorker").
-		// Stats(true). // enable collecting stats
+		Stats(true). // enable collecting stats
 		Workers(2).
 		BulkActions(batchInsert). // commit if # requests >= 1000
 		// BulkSize(2 << 20).               // commit if size of requests >= 2 MB
 		// FlushInterval(30 * time.Second). // commit every 30s
-		// Before(beforeCallback). // func to call before commits
-		// After(afterCallback).   // func to call after commits
-		Do(context.Background())
+		After(failures.after). // collects the failed items
+		Do(ctx)
 
 	if err != nil {
 		return
 	}
 
-	defer p.Close() // don't forget to close me
-
 	// inserting to ES
 	for _, expert := range experts {
-		r := elastic.NewBulkIndexRequest().Index("experts").Type("data").Id(strconv.Itoa(expert.ID)).Doc(expert)
+		if ctx.Err() != nil {
+			// cancelled; whatever has been added so far is flushed on close
+			break
+		}
+
+		r := elastic.NewBulkIndexRequest().Index(index).Type("data").Id(strconv.Itoa(expert.ID)).Doc(expert)
 
 		// Add the request r to the processor p
 		p.Add(r)
-
 	}
 
-	// Get a snapshot of stats (always blank if not enabled--see above)
-	// stats := p.Stats()
+	// flushes the remaining requests
+	err = p.Close()
 
-	// fmt.Printf("Number of times flush has been invoked: %d\n", stats.Flushed)
-	// fmt.Printf("Number of times workers committed reqs: %d\n", stats.Committed)
-	// fmt.Printf("Number of requests indexed            : %d\n", stats.Indexed)
-	// fmt.Printf("Number of requests reported as created: %d\n", stats.Created)
-	// fmt.Printf("Number of requests reported as updated: %d\n", stats.Updated)
-	// fmt.Printf("Number of requests reported as success: %d\n", stats.Succeeded)
-	// fmt.Printf("Number of requests reported as failed : %d\n", stats.Failed)
+	ps := p.Stats()
+	stats = IndexStats{
+		Indexed:  ps.Succeeded,
+		Failed:   ps.Failed,
+		Flushes:  ps.Flushed,
+		Duration: time.Since(t),
+	}
 
-	// for i, w := range stats.Workers {
-	// 	fmt.Printf("Worker %d: Number of requests queued: %d\n", i, w.Queued)
-	// 	fmt.Printf("           Last response time       : %v\n", w.LastDuration)
-	// }
+	if err != nil {
+		return
+	}
+	if err = ctx.Err(); err != nil {
+		return
+	}
 
-	// to flush the bulkprocessr manualy | otherwise use the params BulkActions, BulkSize or FlushInterval to flush
-	// err = p.Flush()
-	// checkErr(err)
-	return
+	return stats, failures.err()
 }
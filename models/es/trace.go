@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	elastic "gopkg.in/olivere/elastic.v6"
+)
+
+// Trace records the ES searches made with a context, see WithTrace. The searches are made with
+// the ES explain, so every hit tells how its score was computed
+type Trace struct {
+	mu       sync.Mutex
+	searches []TracedSearch
+}
+
+// TracedSearch is a single ES search with its raw hits
+type TracedSearch struct {
+	// Did is the deployment the search was limited to; 0 means all of them
+	Did   int             `json:"did"`
+	Query json.RawMessage `json:"query"`
+	Total int64           `json:"total"`
+	Hits  []TracedHit     `json:"hits"`
+}
+
+// TracedHit is a raw ES hit
+type TracedHit struct {
+	ID          string                     `json:"_id"`
+	Score       float64                    `json:"_score"`
+	Source      json.RawMessage            `json:"_source"`
+	Explanation *elastic.SearchExplanation `json:"_explanation,omitempty"`
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context recording every search made with it in the trace
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+func traceFrom(ctx context.Context) *Trace {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+
+	return t
+}
+
+// Take returns the searches recorded since the last call
+func (t *Trace) Take() (searches []TracedSearch) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	searches, t.searches = t.searches, nil
+
+	return
+}
+
+func (t *Trace) add(did int, nss *elastic.SearchSource, hits *elastic.SearchHits) {
+	s := TracedSearch{Did: did}
+
+	if src, err := nss.Source(); err == nil {
+		s.Query, _ = json.Marshal(src)
+	}
+
+	if hits != nil {
+		s.Total = hits.TotalHits
+
+		for _, hit := range hits.Hits {
+			h := TracedHit{ID: hit.Id, Explanation: hit.Explanation}
+			if hit.Score != nil {
+				h.Score = *hit.Score
+			}
+			if hit.Source != nil {
+				h.Source = *hit.Source
+			}
+
+			s.Hits = append(s.Hits, h)
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.searches = append(t.searches, s)
+}
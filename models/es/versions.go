@@ -0,0 +1,150 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	elastic "gopkg.in/olivere/elastic.v6"
+)
+
+/*
+The experts are searched through the `experts` alias. Every dump builds a new version of the index
+(`experts_v<timestamp>`) and once it's validated the alias is moved to it in one request, so the
+matching never sees an empty or a partially indexed deployment
+*/
+
+const (
+	alias         = "experts"
+	versionPrefix = alias + "_v"
+)
+
+// versionName returns a name for a new version; the names sort in the creation order
+func versionName(t time.Time) string {
+	return versionPrefix + t.UTC().Format("20060102150405")
+}
+
+// CreateVersion creates a new, empty version of the experts index with the mapping
+func (db *DB) CreateVersion(ctx context.Context) (index string, err error) {
+	index = versionName(time.Now())
+
+	err = createIndex(ctx, db.Client, index)
+
+	return
+}
+
+// CurrentVersion returns the index behind the experts alias. For the installations from before
+// the aliases it returns the concrete `experts` index, and "" when there is no index at all
+func (db *DB) CurrentVersion(ctx context.Context) (string, error) {
+	res, err := db.Aliases().Index("_all").Do(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	indices := res.IndicesByAlias(alias)
+	if len(indices) > 1 {
+		return "", fmt.Errorf("Alias %s points to more than one index: %v", alias, indices)
+	}
+	if len(indices) == 1 {
+		return indices[0], nil
+	}
+
+	exists, err := db.IndexExists(alias).Do(ctx)
+	if err != nil || !exists {
+		return "", err
+	}
+
+	return alias, nil
+}
+
+// Versions returns all the versions of the experts index, the oldest first
+func (db *DB) Versions(ctx context.Context) (versions []string, err error) {
+	names, err := db.IndexNames()
+	if err != nil {
+		return
+	}
+
+	for _, name := range names {
+		if strings.HasPrefix(name, versionPrefix) {
+			versions = append(versions, name)
+		}
+	}
+
+	sort.Strings(versions)
+
+	return
+}
+
+// CopyDeployments copies the experts of all the deployments but the excluded ones between the indices
+func (db *DB) CopyDeployments(ctx context.Context, from, to string, exclDids []int) (copied int64, err error) {
+	q := elastic.NewBoolQuery()
+	for _, did := range exclDids {
+		q.MustNot(elastic.NewMatchPhraseQuery("did", did))
+	}
+
+	res, err := db.Reindex().
+		Source(elastic.NewReindexSource().Index(from).Query(q)).
+		DestinationIndex(to).
+		Refresh("true").
+		Do(ctx)
+	if err != nil {
+		return
+	}
+
+	if len(res.Failures) > 0 {
+		return res.Created, fmt.Errorf("Copying from %s to %s failed for %d experts: %v", from, to, len(res.Failures), res.Failures[0])
+	}
+	if res.Created != res.Total {
+		return res.Created, fmt.Errorf("Copying from %s to %s: %d experts created out of %d", from, to, res.Created, res.Total)
+	}
+
+	return res.Created, nil
+}
+
+// CountVersion counts the experts of the deployment in the given index (alias or version)
+func (db *DB) CountVersion(ctx context.Context, index string, did int) (count int64, err error) {
+	// the documents indexed just before have to be visible
+	if _, err = db.Refresh(index).Do(ctx); err != nil {
+		return
+	}
+
+	return db.Client.Count(index).Type("data").Query(elastic.NewMatchPhraseQuery("did", did)).Do(ctx)
+}
+
+// SwapAlias moves the experts alias from one version to another in one request. A concrete
+// `experts` index (from before the aliases) is deleted in the same request to free the name
+func (db *DB) SwapAlias(ctx context.Context, from, to string) (err error) {
+	actions := []elastic.AliasAction{elastic.NewAliasAddAction(alias).Index(to)}
+
+	switch from {
+	case "":
+	case alias:
+		actions = append(actions, elastic.NewAliasRemoveIndexAction(alias))
+	default:
+		actions = append(actions, elastic.NewAliasRemoveAction(alias).Index(from))
+	}
+
+	res, err := db.Alias().Action(actions...).Do(ctx)
+	if err != nil {
+		return
+	}
+
+	if !res.Acknowledged {
+		return fmt.Errorf("Moving the alias %s to %s couldn't be acknowledged", alias, to)
+	}
+
+	return
+}
+
+// DeleteVersion deletes a version of the experts index
+func (db *DB) DeleteVersion(ctx context.Context, index string) (err error) {
+	if !strings.HasPrefix(index, versionPrefix) {
+		return fmt.Errorf("%s is not a version of the %s index", index, alias)
+	}
+
+	_, err = db.DeleteIndex(index).Do(ctx)
+
+	return
+}
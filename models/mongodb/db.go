@@ -12,8 +12,9 @@ import (
 
 type Repository interface {
 	ClearCollection() (int64, error)
-	Onekeys(out chan<- map[string]string)
-	CountOneKeyOcc(custName, fn, ln string) int64
+	Onekeys(out chan<- map[string]string, after string)
+	Onekey(id string) (map[string]string, error)
+	OneKeyCluster(custName, fn, ln string) ([]string, error)
 	IsInOneKeyDB(fn, mn, ln string) bool
 
 	Flush(operations []mongo.WriteModel) error
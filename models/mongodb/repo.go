@@ -57,7 +57,9 @@ func (db *DB) IsInOneKeyDB(fn, mn, ln string) bool {
 	return false
 }
 
-func (db *DB) CountOneKeyOcc(custName, fn, ln string) int64 {
+// OneKeyCluster returns the SRC_CUST_IDs of the other OneKey people sharing the name signature (first name
+// or its initial if only one character given, and the last name). Empty if the person is unique in OneKey
+func (db *DB) OneKeyCluster(custName, fn, ln string) (onekeys []string, err error) {
 	// defining the collection
 	collection := db.Collection("test2")
 
@@ -67,7 +69,7 @@ func (db *DB) CountOneKeyOcc(custName, fn, ln string) int64 {
 		ifn = primitive.Regex{Pattern: "^" + fn + ".*", Options: ""}
 	}
 
-	// db.collection.find({SRC_LAST_NAME:"ALDERS",SRC_FIRST_NAME:/^M.*/}).count()
+	// db.collection.distinct("SRC_CUST_ID", {SRC_LAST_NAME:"ALDERS",SRC_FIRST_NAME:/^M.*/})
 	filter := bson.D{
 		{"FIRST_NAME", ifn},
 		{"LAST_NAME", ln},
@@ -76,18 +78,18 @@ func (db *DB) CountOneKeyOcc(custName, fn, ln string) int64 {
 		}},
 	}
 
-	// Pass these options to the Find method
-	// options := options.Count()
-
-	counter, err := collection.Count(context.TODO(), filter, nil)
-
+	values, err := collection.Distinct(context.TODO(), "SRC_CUST_ID", filter)
 	if err != nil {
-		// no match, it is truly unique
-		panic(err)
+		return
 	}
 
-	// entry found, skip!
-	return counter
+	for _, v := range values {
+		if onekey, ok := v.(string); ok {
+			onekeys = append(onekeys, onekey)
+		}
+	}
+
+	return
 }
 
 func newTrue() *bool {
@@ -95,7 +97,40 @@ func newTrue() *bool {
 	return &b
 }
 
-func (db *DB) Onekeys(out chan<- map[string]string) {
+// onekeyProjection are the fields of a OneKey row used by the matching
+var onekeyProjection = bson.D{
+	{"_id", 0},
+	{"FIRST_NAME", 1},
+	{"MIDDLE_NAME", 1},
+	{"LAST_NAME", 1},
+	{"CUST_NAME", 1},
+	{"SRC_CUST_ID", 1},
+	{"CITY", 1},
+	{"CNTRY", 1},
+	{"SPECIALTY", 1},
+	{"POSTCODE", 1},
+	{"WORKPLACE", 1},
+}
+
+// Onekey returns a single OneKey row by its SRC_CUST_ID (the _id); nil if there is no such row
+func (db *DB) Onekey(id string) (row map[string]string, err error) {
+	// defining the collection
+	collection := db.Collection("test2")
+
+	options := options.FindOne()
+	options.SetProjection(onekeyProjection)
+
+	err = collection.FindOne(context.TODO(), bson.D{{"_id", id}}, options).Decode(&row)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	return
+}
+
+// Onekeys streams the OneKey rows ordered by the SRC_CUST_ID (which is also the _id).
+// If after is not empty only the rows with greater IDs are returned; this is how the runs are resumed
+func (db *DB) Onekeys(out chan<- map[string]string, after string) {
 	defer close(out)
 
 	// defining the collection
@@ -103,18 +138,14 @@ func (db *DB) Onekeys(out chan<- map[string]string) {
 
 	// filter := bson.D{{"SRC_CUST_ID", "WDEM01690729"}}
 	filter := bson.D{{}}
+	if after != "" {
+		filter = bson.D{{"_id", bson.D{{"$gt", after}}}}
+	}
 
 	// Pass these options to the Find method
 	options := options.Find()
-	options.SetProjection(bson.D{
-		{"_id", 0},
-		{"FIRST_NAME", 1},
-		{"LAST_NAME", 1},
-		{"CUST_NAME", 1},
-		{"SRC_CUST_ID", 1},
-		{"CITY", 1},
-		{"CNTRY", 1},
-	})
+	options.SetProjection(onekeyProjection)
+	options.SetSort(bson.D{{"_id", 1}})
 	options.NoCursorTimeout = newTrue()
 	// options.SetLimit(10)
 
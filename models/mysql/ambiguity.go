@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ambiguity is a OneKey person who couldn't be matched because OneKey has more people with the same
+// name signature (table: kol__onekey_ambiguity, see deployments/mysql/schema.sql)
+type Ambiguity struct {
+	RunID     string    `json:"runId"`
+	Onekey    string    `json:"onekey"`
+	Did       int       `json:"did"`
+	Strategy  string    `json:"strategy"`
+	Signature string    `json:"signature"`
+	Onekeys   []string  `json:"onekeys"`
+	KIDs      []int     `json:"kids"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SaveAmbiguity upserts the ambiguity; the same one found again within the same run replaces the old one
+func (db *DB) SaveAmbiguity(a *Ambiguity) (err error) {
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+
+	kids := []string{}
+	for _, kid := range a.KIDs {
+		kids = append(kids, strconv.Itoa(kid))
+	}
+
+	_, err = db.Exec(`
+INSERT INTO kol__onekey_ambiguity
+	(run_id, onekey, did, strategy, signature, onekeys, kids, created_at)
+VALUES
+	(?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+	signature = VALUES(signature), onekeys = VALUES(onekeys), kids = VALUES(kids), created_at = VALUES(created_at)`,
+		a.RunID, a.Onekey, a.Did, a.Strategy, a.Signature, strings.Join(a.Onekeys, ","), strings.Join(kids, ","), a.CreatedAt,
+	)
+
+	return
+}
+
+// ClearAmbiguities removes all the ambiguities stored for the given run
+func (db *DB) ClearAmbiguities(runID string) (deleted int64, err error) {
+	result, err := db.Exec("DELETE FROM kol__onekey_ambiguity WHERE run_id = ?", runID)
+	if err != nil {
+		return
+	}
+
+	return result.RowsAffected()
+}
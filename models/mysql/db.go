@@ -4,12 +4,35 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
-	"sync"
+	"time"
 )
 
 type Repository interface {
-	AddOnekeyToKOL(wg *sync.WaitGroup, id, did int, oneky string) (int64, error)
+	AddOnekeyToKOL(id, did int, oneky string) (int64, error)
+	OnekeyLinks(did int) (map[string][]int, error)
 	FetchExperts(id, did, batchLimit int, countries []string) (int, []*Experts, error)
+	FetchChangedExperts(id, did, batchLimit int, countries []string, since time.Time) (int, []*Experts, error)
+	ExpertIDs(did int) (map[int]bool, error)
+	Nicknames() ([][2]string, error)
+
+	// incremental dumps
+	Now() (time.Time, error)
+	SyncMark(did int) (time.Time, error)
+	SaveSyncMark(did int, t time.Time) error
+	Lock(name string, timeout time.Duration) (func(), error)
+
+	// match results
+	SaveMatchResult(r *MatchResult) error
+	ClearMatchResults(runID string) (int64, error)
+	SaveAmbiguity(a *Ambiguity) error
+	ClearAmbiguities(runID string) (int64, error)
+
+	// runs
+	SaveRun(r *Run) error
+	Run(id string) (*Run, error)
+	Runs(status string) ([]*Run, error)
+	SaveCheckpoint(runID string, did int, onekey string) error
+	Checkpoints(runID string) (map[int]string, error)
 }
 
 type DB struct {
@@ -40,7 +63,7 @@ func MysqlClient() (*DB, error) {
 
 	// s := fmt.Sprintf("Hi, my name is %s and I'm %d years old.", "Bob", 23)
 	// s := fmt.Sprint("[age:", i, "]")
-	set := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8",
+	set := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=true",
 		user, pass, host, port, dbname,
 	)
 
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+)
+
+// MatchResult is a single OneKey <-> KOL link found by the matching together with its provenance
+// (table: kol__onekey_match, see deployments/mysql/schema.sql)
+type MatchResult struct {
+	RunID    string  `json:"runId"`
+	Onekey   string  `json:"onekey"`
+	KID      int     `json:"kid"`
+	Did      int     `json:"did"`
+	Strategy string  `json:"strategy"`
+	Score    float64 `json:"score"`
+	// LowConfidence marks the matches which always need a review (eg. the fuzzy strategy)
+	LowConfidence bool      `json:"lowConfidence"`
+	InputFn       string    `json:"inputFn"`
+	InputMn       string    `json:"inputMn"`
+	InputLn       string    `json:"inputLn"`
+	MatchedFn     string    `json:"matchedFn"`
+	MatchedMn     string    `json:"matchedMn"`
+	MatchedLn     string    `json:"matchedLn"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// SaveMatchResult upserts the result; the same link found again within the same run replaces the old one
+func (db *DB) SaveMatchResult(r *MatchResult) (err error) {
+	if r.CreatedAt.IsZero() {
+		r.CreatedAt = time.Now()
+	}
+
+	_, err = db.Exec(`
+INSERT INTO kol__onekey_match
+	(run_id, onekey, kid, did, strategy, score, low_confidence, input_fn, input_mn, input_ln, matched_fn, matched_mn, matched_ln, created_at)
+VALUES
+	(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+	strategy = VALUES(strategy), score = VALUES(score), low_confidence = VALUES(low_confidence),
+	input_fn = VALUES(input_fn), input_mn = VALUES(input_mn), input_ln = VALUES(input_ln),
+	matched_fn = VALUES(matched_fn), matched_mn = VALUES(matched_mn), matched_ln = VALUES(matched_ln),
+	created_at = VALUES(created_at)`,
+		r.RunID, r.Onekey, r.KID, r.Did, r.Strategy, r.Score, r.LowConfidence,
+		r.InputFn, r.InputMn, r.InputLn, r.MatchedFn, r.MatchedMn, r.MatchedLn,
+		r.CreatedAt,
+	)
+
+	return
+}
+
+// ClearMatchResults removes all the results stored for the given run
+func (db *DB) ClearMatchResults(runID string) (deleted int64, err error) {
+	result, err := db.Exec("DELETE FROM kol__onekey_match WHERE run_id = ?", runID)
+	if err != nil {
+		return
+	}
+
+	return result.RowsAffected()
+}
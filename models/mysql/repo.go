@@ -3,9 +3,9 @@ package models
 import (
 	"database/sql"
 	"strings"
-	"sync"
+	"time"
 
-	strutils "github.com/tomekwlod/utils/strings"
+	"github.com/tomekwlod/okpii/names"
 )
 
 type Experts struct {
@@ -27,12 +27,19 @@ type Experts struct {
 	Country           string   `json:"country"`
 	City              string   `json:"city"`
 	Aliases           []string `json:"aliases"`
-}
 
-func (db *DB) AddOnekeyToKOL(wg *sync.WaitGroup, id, did int, oneky string) (status int64, err error) {
-	defer wg.Done()
+	// the phonetic (Cologne) codes and the transliterated last name, see the phonetic strategy
+	FnPhonetic string `json:"fnPhonetic"`
+	LnPhonetic string `json:"lnPhonetic"`
+	LnTranslit string `json:"lnTranslit"`
+}
 
-	result, err := db.Exec("INSERT INTO kol__onekey SET onekey=?, kid=?, did=?", oneky, id, did)
+// AddOnekeyToKOL links the OneKey to the kol; an existing link is left as it is (the status is 0 then),
+// so the matching can be re-run. It relies on the unique key of kol__onekey, see deployments/mysql/schema.sql
+func (db *DB) AddOnekeyToKOL(id, did int, oneky string) (status int64, err error) {
+	result, err := db.Exec(`
+INSERT INTO kol__onekey SET onekey=?, kid=?, did=?
+ON DUPLICATE KEY UPDATE onekey = onekey`, oneky, id, did)
 	if err != nil {
 		return
 	}
@@ -42,7 +49,41 @@ func (db *DB) AddOnekeyToKOL(wg *sync.WaitGroup, id, did int, oneky string) (sta
 	return
 }
 
+// OnekeyLinks returns the kol ids already linked to every OneKey within the deployment
+func (db *DB) OnekeyLinks(did int) (links map[string][]int, err error) {
+	links = map[string][]int{}
+
+	rows, err := db.Query("SELECT onekey, kid FROM kol__onekey WHERE did = ?", did)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var onekey string
+		var kid int
+
+		if err = rows.Scan(&onekey, &kid); err != nil {
+			return
+		}
+
+		links[onekey] = append(links[onekey], kid)
+	}
+
+	return links, rows.Err()
+}
+
 func (db *DB) FetchExperts(id, did, batchLimit int, countries []string) (newID int, result []*Experts, err error) {
+	return db.fetchExperts(id, did, batchLimit, countries, time.Time{})
+}
+
+// FetchChangedExperts pages through the experts of the deployment updated (kol.updated_at) since the given time
+func (db *DB) FetchChangedExperts(id, did, batchLimit int, countries []string, since time.Time) (newID int, result []*Experts, err error) {
+	return db.fetchExperts(id, did, batchLimit, countries, since)
+}
+
+// fetchExperts pages through the experts by their ids; a zero since means all the experts
+func (db *DB) fetchExperts(id, did, batchLimit int, countries []string, since time.Time) (newID int, result []*Experts, err error) {
 	newID = id
 	// later, if bigger queries: https://dev.to/backendandbbq/the-sql-i-love-chapter-one
 
@@ -55,13 +96,19 @@ func (db *DB) FetchExperts(id, did, batchLimit int, countries []string) (newID i
 		countriesQuery = "AND (" + (strings.Join(tmp, " OR ")) + ") "
 	}
 
+	args := []interface{}{did}
+	sinceQuery := ""
+	if !since.IsZero() {
+		sinceQuery = "AND k.updated_at >= ? "
+		args = append(args, since)
+	}
+	args = append(args, newID, batchLimit)
+
 	rows, err := db.Query(`
 SELECT 
 	k.id, k.first_name as fn, k.last_name as ln, k.middle_name as mn, k.npi, k.ttid, k.deployment_id as did, r.position, l.city, l.country_name as country, 
 	GROUP_CONCAT(distinct ke.first_name SEPARATOR ' ;;; ') as fn1,
-	GROUP_CONCAT(distinct cem.firstName SEPARATOR ' ;;; ') as fn2, 
-	(select GROUP_CONCAT(distinct name SEPARATOR ' ;;; ') from firstname where nickname = k.first_name) as fn3, 
-	(select GROUP_CONCAT(distinct nickname SEPARATOR ' ;;; ') from firstname where name = k.first_name) as fn4
+	GROUP_CONCAT(distinct cem.firstName SEPARATOR ' ;;; ') as fn2
 FROM kol k
 LEFT JOIN rank_score_kol r ON r.kol_id = k.id
 left join kol__entry ke on ke.kol_id = k.id and length(ke.first_name ) > 1 and ke.first_name <> k.first_name
@@ -71,14 +118,16 @@ LEFT JOIN location l ON l.id = k.default_location_id
 WHERE
 	k.deployment_id = ?
 	`+countriesQuery+`
+	`+sinceQuery+`
 	AND k.id > ?
 group by k.id
 ORDER BY k.id ASC
-LIMIT ?`, did, newID, batchLimit)
+LIMIT ?`, args...)
 
 	if err != nil {
 		return
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		row, err := transform(rows)
@@ -91,103 +140,57 @@ LIMIT ?`, did, newID, batchLimit)
 		newID = row.ID
 	}
 
-	return
+	return newID, result, rows.Err()
 }
 
 func transform(rows *sql.Rows) (e *Experts, err error) {
 	var id, did int // if nullable then if should be sql.NullInt64
 	var npi, ttid, position sql.NullInt64
 	var fn, mn, ln, city, country sql.NullString // not just string here because of nulls
-	var fn1, fn2, fn3, fn4 sql.NullString
+	var fn1, fn2 sql.NullString
 
-	err = rows.Scan(&id, &fn, &ln, &mn, &npi, &ttid, &did, &position, &city, &country, &fn1, &fn2, &fn3, &fn4)
+	err = rows.Scan(&id, &fn, &ln, &mn, &npi, &ttid, &did, &position, &city, &country, &fn1, &fn2)
 	if err != nil {
 		return
 	}
 
-	aliases := mergeAliases(fn1, fn2, fn3, fn4)
-
-	// this is a case when the middle name is either empty or contains a single letter
-	// also first name has to contain a space or a dash
-	//
-	// basically it's trying to modify the middle name if better found in first name
-	for _, separator := range []string{" ", "-"} {
-		fne := strings.Split(fn.String, separator)
-		if len(fne) > 1 {
-			if mn.String == "" {
-				fn.String = fne[0]
-				mn.String = strings.Join(fne[1:], separator)
-
-				break
-			} else {
-				if len(mn.String) == 1 && strutils.FirstChar(strings.Join(fne[1:], " ")) == mn.String {
-					fn.String = fne[0]
-					mn.String = strings.Join(fne[1:], separator)
-
-					break
-				}
-			}
-		}
-	}
-	// end of the middle name modification
-
-	// only if fn doesn't include: `-` , `.` , ` `  . Above statement shoudl take care of them ^^^^
-	if !strings.Contains(fn.String, " ") && !strings.Contains(fn.String, ".") && !strings.Contains(fn.String, "-") {
-		for _, separator := range []string{" ", "-", "."} {
-			mne := strings.Split(mn.String, separator)
-
-			if len(mne) > 1 {
-				if strutils.FirstChar(fn.String) == mne[0] {
-					// Adam A.M. Smith ---> Adam M. Smith
-					// Adam A Smith   !---> Adam Smith    <-- probably too risky
-					mn.String = strings.Join(mne[1:], separator)
-				}
-			}
-		}
-	}
-	// end of the middle name modification
+	// the names the expert published with; the nicknames (firstname table) are merged by the dump, see the aliases package
+	aliases := mergeAliases(fn1, fn2)
 
-	// remove the dots from the middle name
-	if mn.String != "" {
-		mn.String = strings.Replace(mn.String, ".", " ", -1)
-	}
-
-	mnstr := " "
-	if mn.String != "" {
-		mnstr = " " + mn.String + " "
-	}
-	name := "" + fn.String + mnstr + ln.String
-	squash := strings.Replace(strings.Replace(name, "-", "", -1), " ", "", -1)
+	// the same parsing as for OneKey: no titles nor suffixes, the particles in the last name
+	n := names.Parse(fn.String, mn.String, ln.String)
+	nn := names.Normalize(n.Fn, n.Mn, n.Ln)
 
 	e = &Experts{
 		ID:                id,
 		Did:               did,
 		NPI:               int(npi.Int64),
 		TTID:              int(ttid.Int64),
-		Fn:                fn.String,
-		Mn:                mn.String,
-		Ln:                ln.String,
-		Name:              name,
-		NameKeyword:       name,
-		NameKeywordSquash: squash,
-		NameKeywordRaw:    squash,
+		Fn:                nn.Fn,
+		Mn:                nn.Mn,
+		Ln:                nn.Ln,
+		Name:              nn.Name,
+		NameKeyword:       nn.Name,
+		NameKeywordSquash: nn.Squash,
+		NameKeywordRaw:    nn.Squash,
 		Deleted:           0,
-		FNDash:            strings.Contains(fn.String, "-"),
-		FNDot:             strings.Contains(fn.String, "."),
+		FNDash:            strings.Contains(nn.Fn, "-"),
+		FNDot:             strings.Contains(nn.Fn, "."),
 		Position:          int(position.Int64),
 		City:              city.String,
 		Country:           country.String,
 		Aliases:           aliases,
+		FnPhonetic:        names.Cologne(nn.Fn),
+		LnPhonetic:        names.Cologne(nn.Ln),
+		LnTranslit:        names.Transliterate(nn.Ln),
 	}
 
 	return
 }
 
-func mergeAliases(fn1, fn2, fn3, fn4 sql.NullString) (aliases []string) {
+func mergeAliases(fn1, fn2 sql.NullString) (aliases []string) {
 	s1 := strings.Split(fn1.String, " ;;; ")
 	s2 := strings.Split(fn2.String, " ;;; ")
-	s3 := strings.Split(fn3.String, " ;;; ")
-	s4 := strings.Split(fn4.String, " ;;; ")
 
 	set := make(map[string]string)
 
@@ -207,22 +210,6 @@ func mergeAliases(fn1, fn2, fn3, fn4 sql.NullString) (aliases []string) {
 			set[s] = s
 		}
 	}
-	for _, s := range s3 {
-		if s != "" {
-			if strings.Contains(s, ".") && len(s) == 2 {
-				continue
-			}
-			set[s] = s
-		}
-	}
-	for _, s := range s4 {
-		if s != "" {
-			if strings.Contains(s, ".") && len(s) == 2 {
-				continue
-			}
-			set[s] = s
-		}
-	}
 
 	for _, s := range set {
 		aliases = append(aliases, s)
@@ -230,3 +217,24 @@ func mergeAliases(fn1, fn2, fn3, fn4 sql.NullString) (aliases []string) {
 
 	return
 }
+
+// Nicknames returns the pairs of the firstname table (name, nickname)
+func (db *DB) Nicknames() (pairs [][2]string, err error) {
+	rows, err := db.Query("SELECT name, nickname FROM firstname WHERE name <> '' AND nickname <> ''")
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pair [2]string
+
+		if err = rows.Scan(&pair[0], &pair[1]); err != nil {
+			return
+		}
+
+		pairs = append(pairs, pair)
+	}
+
+	return pairs, rows.Err()
+}
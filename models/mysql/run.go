@@ -0,0 +1,141 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	RunRunning   = "running"
+	RunCompleted = "completed"
+)
+
+// Run is a single execution of the matching command
+// (tables: kol__onekey_run, kol__onekey_checkpoint, see deployments/mysql/schema.sql)
+type Run struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Deployments string     `json:"deployments"`
+	Processed   int        `json:"processed"`
+	Matched     int        `json:"matched"`
+	StartedAt   time.Time  `json:"startedAt"`
+	FinishedAt  *time.Time `json:"finishedAt"`
+}
+
+// Duration returns how long the run took or, if it is not finished yet, how long it has been running
+func (r *Run) Duration() time.Duration {
+	if r.FinishedAt == nil {
+		return time.Since(r.StartedAt)
+	}
+
+	return r.FinishedAt.Sub(r.StartedAt)
+}
+
+// SaveRun inserts or updates the run
+func (db *DB) SaveRun(r *Run) (err error) {
+	_, err = db.Exec(`
+INSERT INTO kol__onekey_run
+	(id, status, deployments, processed, matched, started_at, finished_at)
+VALUES
+	(?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+	status = VALUES(status), deployments = VALUES(deployments),
+	processed = VALUES(processed), matched = VALUES(matched), finished_at = VALUES(finished_at)`,
+		r.ID, r.Status, r.Deployments, r.Processed, r.Matched, r.StartedAt, r.FinishedAt,
+	)
+
+	return
+}
+
+// Run returns the run with the given ID or an error if it doesn't exist
+func (db *DB) Run(id string) (*Run, error) {
+	rows, err := db.Query(`
+SELECT id, status, deployments, processed, matched, started_at, finished_at
+FROM kol__onekey_run
+WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, sql.ErrNoRows
+	}
+
+	return scanRun(rows)
+}
+
+// Runs returns the runs with the given status (all of them if empty), the newest first
+func (db *DB) Runs(status string) (runs []*Run, err error) {
+	rows, err := db.Query(`
+SELECT id, status, deployments, processed, matched, started_at, finished_at
+FROM kol__onekey_run
+WHERE ? = '' OR status = ?
+ORDER BY started_at DESC`, status, status)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, r)
+	}
+
+	return runs, rows.Err()
+}
+
+func scanRun(rows *sql.Rows) (r *Run, err error) {
+	r = &Run{}
+
+	err = rows.Scan(&r.ID, &r.Status, &r.Deployments, &r.Processed, &r.Matched, &r.StartedAt, &r.FinishedAt)
+
+	return
+}
+
+// SaveCheckpoint stores the last OneKey ID fully processed for the deployment
+func (db *DB) SaveCheckpoint(runID string, did int, onekey string) (err error) {
+	_, err = db.Exec(`
+INSERT INTO kol__onekey_checkpoint
+	(run_id, did, onekey, updated_at)
+VALUES
+	(?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+	onekey = VALUES(onekey), updated_at = VALUES(updated_at)`,
+		runID, did, onekey, time.Now(),
+	)
+
+	return
+}
+
+// Checkpoints returns the last processed OneKey ID per deployment
+func (db *DB) Checkpoints(runID string) (checkpoints map[int]string, err error) {
+	checkpoints = map[int]string{}
+
+	rows, err := db.Query("SELECT did, onekey FROM kol__onekey_checkpoint WHERE run_id = ?", runID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var did int
+		var onekey string
+
+		if err = rows.Scan(&did, &onekey); err != nil {
+			return
+		}
+
+		checkpoints[did] = onekey
+	}
+
+	return checkpoints, rows.Err()
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Now returns the time of the MySQL server; the marks are compared with kol.updated_at so
+// they have to come from the same clock
+func (db *DB) Now() (now time.Time, err error) {
+	err = db.QueryRow("SELECT NOW()").Scan(&now)
+
+	return
+}
+
+// SyncMark returns the time the last successful dump of the deployment started at
+// (table: kol__dump_mark, see deployments/mysql/schema.sql); zero time if there was no dump yet
+func (db *DB) SyncMark(did int) (mark time.Time, err error) {
+	err = db.QueryRow("SELECT synced_at FROM kol__dump_mark WHERE did = ?", did).Scan(&mark)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+
+	return
+}
+
+// SaveSyncMark stores the high-water mark of the deployment
+func (db *DB) SaveSyncMark(did int, t time.Time) (err error) {
+	_, err = db.Exec(`
+INSERT INTO kol__dump_mark (did, synced_at) VALUES (?, ?)
+ON DUPLICATE KEY UPDATE synced_at = VALUES(synced_at)`, did, t)
+
+	return
+}
+
+// ExpertIDs returns the ids of all the experts of the deployment (no matter the country)
+func (db *DB) ExpertIDs(did int) (ids map[int]bool, err error) {
+	ids = map[int]bool{}
+
+	rows, err := db.Query("SELECT id FROM kol WHERE deployment_id = ?", did)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return
+		}
+
+		ids[id] = true
+	}
+
+	return ids, rows.Err()
+}
+
+// Lock takes the named MySQL lock (GET_LOCK), waiting up to timeout for it. The lock is held by
+// a dedicated connection until unlock is called; MySQL releases it when the process dies
+func (db *DB) Lock(name string, timeout time.Duration) (unlock func(), err error) {
+	ctx := context.Background()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return
+	}
+
+	var got sql.NullInt64
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, int(timeout/time.Second)).Scan(&got)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	if got.Int64 != 1 {
+		conn.Close()
+		return nil, fmt.Errorf("Lock %s is held by another process", name)
+	}
+
+	unlock = func() {
+		if _, err := conn.ExecContext(ctx, "DO RELEASE_LOCK(?)", name); err != nil {
+			fmt.Printf("Couldn't release the lock %s: %v\n", name, err)
+		}
+		conn.Close()
+	}
+
+	return
+}
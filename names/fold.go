@@ -0,0 +1,78 @@
+// Package names holds the name (and place name) normalisation shared by the indexing and the matching
+package names
+
+import (
+	"strings"
+	"unicode"
+)
+
+// folding maps the accented letters to their ASCII form. The German umlauts are folded to the base
+// letter here; their transliterated form (ü -> ue) is handled by Same
+var folding = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae",
+	'ç': "c", 'ć': "c", 'č': "c",
+	'ď': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i",
+	'ł': "l", 'ľ': "l", 'ĺ': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'œ': "oe",
+	'ŕ': "r", 'ř': "r",
+	'ś': "s", 'š': "s", 'ş': "s", 'ș': "s",
+	'ß': "ss",
+	'ť': "t", 'ţ': "t", 'ț': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// Fold lowercases the string and replaces the accented letters with the ASCII ones, eg. "Düsseldorf" -> "dusseldorf"
+func Fold(s string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if f, ok := folding[r]; ok {
+			b.WriteString(f)
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// Key is the comparable form of the string: folded and with the letters and digits only,
+// eg. "Düsseldorf" -> "dusseldorf"
+func Key(s string) string {
+	var b strings.Builder
+	for _, r := range Fold(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+var umlauts = strings.NewReplacer("ae", "a", "oe", "o", "ue", "u")
+
+// UmlautKey is the Key with the transliterated umlauts (ue, oe, ae) reduced to the base letter,
+// eg. "Muenchen" -> "munchen". It folds the other names too ("Israel" -> "isral"), so it's only
+// the fallback when the Keys differ
+func UmlautKey(s string) string {
+	return umlauts.Replace(Key(s))
+}
+
+// Same tells if both strings are the same after the ASCII-folding, or after the umlaut normalisation
+// otherwise, eg. "München", "Muenchen" and "MUNCHEN" are the same. Empty strings are never the same
+func Same(a, b string) bool {
+	ka, kb := Key(a), Key(b)
+	if ka == "" || kb == "" {
+		return false
+	}
+
+	return ka == kb || UmlautKey(a) == UmlautKey(b)
+}
@@ -0,0 +1,84 @@
+package names
+
+import (
+	"strings"
+
+	strutils "github.com/tomekwlod/utils/strings"
+)
+
+// NormalizedName is a name the way it's indexed and searched
+type NormalizedName struct {
+	Fn string
+	Mn string
+	Ln string
+
+	// Name is "Fn Mn Ln" (or "Fn Ln" without the middle name)
+	Name string
+
+	// Squash is the Name without the spaces and the dashes, eg. "JohnMarkSmith"
+	Squash string
+}
+
+// Normalize repairs the middle name: the middle name is taken from the first name if better found there
+// (Xin-xia Li -> Xin xia Li; Jorge Enrique E Cortes -> Jorge Enrique Cortes), the repeated first initial is removed
+// from the middle name (Adam A.M. Smith -> Adam M Smith) and the dots in the middle name become spaces.
+// It runs on the parsed names, see Parse
+func Normalize(fn, mn, ln string) (n NormalizedName) {
+	// this is a case when the middle name is either empty or contains a single letter
+	// also first name has to contain a space or a dash
+	//
+	// basically it's trying to modify the middle name if better found in first name
+	for _, separator := range []string{" ", "-"} {
+		fne := strings.Split(fn, separator)
+		if len(fne) > 1 {
+			if mn == "" {
+				fn = fne[0]
+				mn = strings.Join(fne[1:], separator)
+
+				break
+			} else {
+				if len(mn) == 1 && strutils.FirstChar(strings.Join(fne[1:], " ")) == mn {
+					fn = fne[0]
+					mn = strings.Join(fne[1:], separator)
+
+					break
+				}
+			}
+		}
+	}
+	// end of the middle name modification
+
+	// only if fn doesn't include: `-` , `.` , ` `  . Above statement shoudl take care of them ^^^^
+	if !strings.Contains(fn, " ") && !strings.Contains(fn, ".") && !strings.Contains(fn, "-") {
+		for _, separator := range []string{" ", "-", "."} {
+			mne := strings.Split(mn, separator)
+
+			if len(mne) > 1 {
+				if strutils.FirstChar(fn) == mne[0] {
+					// Adam A.M. Smith ---> Adam M. Smith
+					// Adam A Smith   !---> Adam Smith    <-- probably too risky
+					mn = strings.Join(mne[1:], separator)
+				}
+			}
+		}
+	}
+	// end of the middle name modification
+
+	// remove the dots from the middle name; A.M. -> A M (no trailing nor double spaces)
+	if mn != "" {
+		mn = strings.Join(strings.Fields(strings.Replace(mn, ".", " ", -1)), " ")
+	}
+
+	mnstr := " "
+	if mn != "" {
+		mnstr = " " + mn + " "
+	}
+
+	n.Fn = fn
+	n.Mn = mn
+	n.Ln = ln
+	n.Name = fn + mnstr + ln
+	n.Squash = strings.Replace(strings.Replace(n.Name, "-", "", -1), " ", "", -1)
+
+	return
+}
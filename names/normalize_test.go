@@ -0,0 +1,41 @@
+package names
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		fn, mn, ln string
+		want       NormalizedName
+	}{
+		// the middle name found in the first name
+		{"Xin-xia", "", "Li", NormalizedName{Fn: "Xin", Mn: "xia", Ln: "Li", Name: "Xin xia Li", Squash: "XinxiaLi"}},
+		{"Jorge Enrique", "", "Cortes", NormalizedName{Fn: "Jorge", Mn: "Enrique", Ln: "Cortes", Name: "Jorge Enrique Cortes", Squash: "JorgeEnriqueCortes"}},
+		{"Jorge Enrique", "E", "Cortes", NormalizedName{Fn: "Jorge", Mn: "Enrique", Ln: "Cortes", Name: "Jorge Enrique Cortes", Squash: "JorgeEnriqueCortes"}},
+		{"Hans-Peter", "P", "Müller", NormalizedName{Fn: "Hans", Mn: "Peter", Ln: "Müller", Name: "Hans Peter Müller", Squash: "HansPeterMüller"}},
+
+		// a middle name not matching the first name is kept
+		{"Jorge Enrique", "A", "Cortes", NormalizedName{Fn: "Jorge Enrique", Mn: "A", Ln: "Cortes", Name: "Jorge Enrique A Cortes", Squash: "JorgeEnriqueACortes"}},
+
+		// the initials are not split
+		{"GJ", "", "Ossenkoppele", NormalizedName{Fn: "GJ", Mn: "", Ln: "Ossenkoppele", Name: "GJ Ossenkoppele", Squash: "GJOssenkoppele"}},
+
+		// the dots become spaces, the trailing one is dropped
+		{"Ralf", "F.", "Dittrich", NormalizedName{Fn: "Ralf", Mn: "F", Ln: "Dittrich", Name: "Ralf F Dittrich", Squash: "RalfFDittrich"}},
+		{"Ralf", "", "Dittrich", NormalizedName{Fn: "Ralf", Mn: "", Ln: "Dittrich", Name: "Ralf Dittrich", Squash: "RalfDittrich"}},
+		{"Mark", "A.M.", "Smith", NormalizedName{Fn: "Mark", Mn: "A M", Ln: "Smith", Name: "Mark A M Smith", Squash: "MarkAMSmith"}},
+
+		// the first initial repeated in the middle name
+		{"Adam", "A.M.", "Smith", NormalizedName{Fn: "Adam", Mn: "M", Ln: "Smith", Name: "Adam M Smith", Squash: "AdamMSmith"}},
+		{"Adam", "A M", "Smith", NormalizedName{Fn: "Adam", Mn: "M", Ln: "Smith", Name: "Adam M Smith", Squash: "AdamMSmith"}},
+		{"Adam", "A", "Smith", NormalizedName{Fn: "Adam", Mn: "A", Ln: "Smith", Name: "Adam A Smith", Squash: "AdamASmith"}},
+
+		// the dashes are squashed
+		{"Anna", "", "Müller-Lüdenscheidt", NormalizedName{Fn: "Anna", Mn: "", Ln: "Müller-Lüdenscheidt", Name: "Anna Müller-Lüdenscheidt", Squash: "AnnaMüllerLüdenscheidt"}},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.fn, tt.mn, tt.ln); got != tt.want {
+			t.Errorf("Normalize(%q, %q, %q) = %+v, want %+v", tt.fn, tt.mn, tt.ln, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package names
+
+import "strings"
+
+// Name is a person's name split into its parts, see Parse
+type Name struct {
+	// Original is the name as given, kept for auditing
+	Original string `json:"original"`
+
+	// Titles are the academic and the courtesy titles, eg. ["Prof.", "Dr. med."]
+	Titles []string `json:"titles,omitempty"`
+
+	Fn string `json:"fn"`
+	Mn string `json:"mn"`
+
+	// Ln is the last name with its particle as written, eg. "von der Heide", "De Luca"
+	Ln string `json:"ln"`
+
+	// Particle is the nobiliary particle of the last name in lowercase, eg. "von der", "de la"
+	Particle string `json:"particle,omitempty"`
+
+	// LnParts are the parts of a compound or a double-barrelled last name without the particle,
+	// eg. ["Müller", "Lüdenscheidt"] for "Müller-Lüdenscheidt" and ["García", "Márquez"] for "García Márquez"
+	LnParts []string `json:"lnParts,omitempty"`
+
+	// Suffix is the generational or the professional suffix, eg. "Jr.", "III", "PhD"
+	Suffix string `json:"suffix,omitempty"`
+}
+
+// titles are always the titles; the lookup key is lowercased without the dots and the dashes,
+// eg. "Priv.-Doz." -> "privdoz"
+var titles = map[string]bool{
+	"dr": true, "drs": true, "prof": true, "pd": true, "privdoz": true, "univprof": true, "apl": true,
+	"drmed": true, "drmeddent": true, "drmedvet": true, "drrernat": true, "drphil": true, "drhc": true,
+	"mudr": true, "dott": true, "dottssa": true, "habil": true, "dipl": true, "diplmed": true, "diplpsych": true,
+}
+
+// dottedTitles are the titles only when written with a dot; "Phil" and "Nat" are first names as well
+var dottedTitles = map[string]bool{
+	"med": true, "dent": true, "vet": true, "rer": true, "nat": true, "phil": true, "hc": true,
+	"mr": true, "mrs": true, "ms": true, "ing": true, "mag": true, "doz": true,
+}
+
+var suffixes = map[string]bool{
+	"jr": true, "sr": true, "ii": true, "iii": true, "iv": true,
+	"phd": true, "md": true, "mba": true, "msc": true, "bsc": true, "frcp": true,
+}
+
+// particles are the words starting a last name; the multi-word ones are just a sequence of them
+var particles = map[string]bool{
+	"von": true, "vom": true, "zu": true, "zum": true, "zur": true, "und": true,
+	"van": true, "der": true, "den": true, "ter": true, "ten": true, "het": true, "'t": true,
+	"de": true, "la": true, "le": true, "les": true, "du": true, "des": true, "del": true, "della": true,
+	"di": true, "da": true, "dos": true, "das": true,
+}
+
+var keyReplacer = strings.NewReplacer(".", "", "-", "", ",", "")
+
+func key(word string) string {
+	return keyReplacer.Replace(strings.ToLower(word))
+}
+
+func isTitle(word string) bool {
+	k := key(word)
+
+	return titles[k] || (dottedTitles[k] && strings.HasSuffix(word, "."))
+}
+
+// Parse splits the name into its parts: the titles (from the beginning of any part) and the suffixes
+// (from the end of any part) are stripped, the particles written in lowercase at the end of the middle
+// name (or of the first name without a middle name) are moved to the last name and the last name is
+// split into its parts:
+//
+//	Parse("Prof. Dr. med. Hans", "", "von der Heide")  -> Hans, von der Heide (particle: von der)
+//	Parse("Hans von", "", "Heide")                      -> Hans, von Heide (particle: von)
+//	Parse("Thi Le", "", "Nguyen")                       -> Thi Le, Nguyen (a capitalised "Le" is a name)
+//	Parse("Anna", "", "Müller-Lüdenscheidt, PhD")       -> Anna, Müller-Lüdenscheidt (parts: Müller, Lüdenscheidt)
+//	Parse("Dr", "", "Smith")                            -> Dr, Smith (a part is never stripped completely)
+//
+// The first name isn't split into the first and the middle names; a middle name given is kept
+func Parse(fn, mn, ln string) (n Name) {
+	n.Original = strings.Join(strings.Fields(strings.Join([]string{fn, mn, ln}, " ")), " ")
+
+	fns := n.strip(fn)
+	mns := n.strip(mn)
+	lns := n.strip(ln)
+
+	// "Hans von" + "Heide"; at least one word of the first name stays where it was
+	var moved []string
+	for len(mns) > 0 && isParticle(mns[len(mns)-1]) {
+		moved = append([]string{mns[len(mns)-1]}, moved...)
+		mns = mns[:len(mns)-1]
+	}
+	for strings.TrimSpace(mn) == "" && len(fns) > 1 && isParticle(fns[len(fns)-1]) {
+		moved = append([]string{fns[len(fns)-1]}, moved...)
+		fns = fns[:len(fns)-1]
+	}
+	lns = append(moved, lns...)
+
+	n.Fn = strings.Join(fns, " ")
+	n.Mn = strings.Join(mns, " ")
+	n.Ln = strings.Join(lns, " ")
+
+	// the particle is the leading particles of the last name, but never the whole last name
+	var particle []string
+	for len(lns) > 1 && particles[strings.ToLower(lns[0])] {
+		particle = append(particle, strings.ToLower(lns[0]))
+		lns = lns[1:]
+	}
+	n.Particle = strings.Join(particle, " ")
+
+	for _, word := range lns {
+		for _, part := range strings.Split(word, "-") {
+			if part != "" {
+				n.LnParts = append(n.LnParts, part)
+			}
+		}
+	}
+	if len(n.LnParts) < 2 {
+		n.LnParts = nil
+	}
+
+	return
+}
+
+// isParticle tells if the word is a particle written in lowercase; "Le", "Da" or "Van" in a first
+// name are names as well (Thi Le, Van Anh)
+func isParticle(word string) bool {
+	return particles[word]
+}
+
+// strip removes the leading titles and the trailing suffixes of the part and returns its words;
+// the last word stays even if it looks like a title or a suffix (eg. "Dr" as the whole first name)
+func (n *Name) strip(part string) []string {
+	words := strings.Fields(strings.Replace(part, ",", " ", -1))
+
+	for len(words) > 1 && isTitle(words[0]) {
+		title := words[0]
+		words = words[1:]
+
+		// "Dr. med." is one title
+		for len(words) > 1 && dottedTitles[key(words[0])] && strings.HasSuffix(words[0], ".") {
+			title += " " + words[0]
+			words = words[1:]
+		}
+
+		n.Titles = append(n.Titles, title)
+	}
+
+	// a single word is a name even if it looks like a suffix (eg. "Md" as a first name)
+	for len(words) > 1 && suffixes[key(words[len(words)-1])] {
+		suffix := words[len(words)-1]
+		if n.Suffix != "" {
+			suffix += " " + n.Suffix
+		}
+
+		n.Suffix = suffix
+		words = words[:len(words)-1]
+	}
+
+	return words
+}
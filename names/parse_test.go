@@ -0,0 +1,43 @@
+package names
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		fn, mn, ln string
+		want       Name
+	}{
+		// the titles and the suffixes
+		{"Prof. Dr. med. Hans", "", "von der Heide", Name{Original: "Prof. Dr. med. Hans von der Heide", Titles: []string{"Prof.", "Dr. med."}, Fn: "Hans", Ln: "von der Heide", Particle: "von der"}},
+		{"Anna", "", "Müller-Lüdenscheidt, PhD", Name{Original: "Anna Müller-Lüdenscheidt, PhD", Fn: "Anna", Ln: "Müller-Lüdenscheidt", LnParts: []string{"Müller", "Lüdenscheidt"}, Suffix: "PhD"}},
+		{"John", "", "Smith Jr.", Name{Original: "John Smith Jr.", Fn: "John", Ln: "Smith", Suffix: "Jr."}},
+
+		// a part is never stripped completely
+		{"Dr", "", "Smith", Name{Original: "Dr Smith", Fn: "Dr", Ln: "Smith"}},
+		{"Md", "", "Rahman", Name{Original: "Md Rahman", Fn: "Md", Ln: "Rahman"}},
+
+		// the lowercase particles move to the last name
+		{"Hans von", "", "Heide", Name{Original: "Hans von Heide", Fn: "Hans", Ln: "von Heide", Particle: "von"}},
+		{"Jan", "van der", "Berg", Name{Original: "Jan van der Berg", Fn: "Jan", Ln: "van der Berg", Particle: "van der"}},
+
+		// the capitalised ones are names
+		{"Thi Le", "", "Nguyen", Name{Original: "Thi Le Nguyen", Fn: "Thi Le", Ln: "Nguyen"}},
+		{"Maria Da", "", "Silva", Name{Original: "Maria Da Silva", Fn: "Maria Da", Ln: "Silva"}},
+
+		// a particle of the first name stays with a middle name given
+		{"Hans von", "P", "Heide", Name{Original: "Hans von P Heide", Fn: "Hans von", Mn: "P", Ln: "Heide"}},
+
+		// the particle of the last name as written
+		{"Carlo", "", "De Luca", Name{Original: "Carlo De Luca", Fn: "Carlo", Ln: "De Luca", Particle: "de"}},
+		{"Anna", "", "Van", Name{Original: "Anna Van", Fn: "Anna", Ln: "Van"}},
+	}
+
+	for _, tt := range tests {
+		if got := Parse(tt.fn, tt.mn, tt.ln); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q, %q, %q) = %+v, want %+v", tt.fn, tt.mn, tt.ln, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package names
+
+import "strings"
+
+// transliteration writes the letters the way they are written without the diacritics by the
+// natives (ü -> ue, ø -> oe), unlike the folding which just drops the diacritics (ü -> u)
+var transliteration = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'ß': "ss",
+	'æ': "ae", 'ø': "oe", 'å': "aa", 'œ': "oe",
+}
+
+// Transliterate lowercases the string and writes it in ASCII, eg. "Hübel" -> "huebel", "Søren" -> "soeren",
+// "Łukasiewicz" -> "lukasiewicz". The letters other than the German and the Nordic ones are folded
+func Transliterate(s string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if t, ok := transliteration[r]; ok {
+			b.WriteString(t)
+			continue
+		}
+		if f, ok := folding[r]; ok {
+			b.WriteString(f)
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// Cologne returns the Cologne phonetics (Kölner Phonetik) code of the string, eg. "Müller" and
+// "Mueller" -> "657", "Meier", "Mayer" and "Maier" -> "67". Every word is encoded separately
+func Cologne(s string) string {
+	words := strings.FieldsFunc(strings.ToUpper(Fold(s)), func(r rune) bool {
+		return r < 'A' || r > 'Z'
+	})
+
+	codes := []string{}
+	for _, w := range words {
+		if c := cologneWord(w); c != "" {
+			codes = append(codes, c)
+		}
+	}
+
+	return strings.Join(codes, " ")
+}
+
+func cologneWord(w string) string {
+	var digits []byte
+
+	for i := 0; i < len(w); i++ {
+		var prev, next byte
+		if i > 0 {
+			prev = w[i-1]
+		}
+		if i < len(w)-1 {
+			next = w[i+1]
+		}
+
+		switch c := w[i]; c {
+		case 'A', 'E', 'I', 'J', 'O', 'U', 'Y':
+			digits = append(digits, '0')
+		case 'H':
+			// ignored
+		case 'B':
+			digits = append(digits, '1')
+		case 'P':
+			if next == 'H' {
+				digits = append(digits, '3')
+			} else {
+				digits = append(digits, '1')
+			}
+		case 'D', 'T':
+			if strings.IndexByte("CSZ", next) >= 0 && next != 0 {
+				digits = append(digits, '8')
+			} else {
+				digits = append(digits, '2')
+			}
+		case 'F', 'V', 'W':
+			digits = append(digits, '3')
+		case 'G', 'K', 'Q':
+			digits = append(digits, '4')
+		case 'C':
+			switch {
+			case i == 0 && next != 0 && strings.IndexByte("AHKLOQRUX", next) >= 0:
+				digits = append(digits, '4')
+			case i > 0 && next != 0 && strings.IndexByte("AHKOQUX", next) >= 0 && prev != 'S' && prev != 'Z':
+				digits = append(digits, '4')
+			default:
+				digits = append(digits, '8')
+			}
+		case 'X':
+			if prev != 0 && strings.IndexByte("CKQ", prev) >= 0 {
+				digits = append(digits, '8')
+			} else {
+				digits = append(digits, '4', '8')
+			}
+		case 'L':
+			digits = append(digits, '5')
+		case 'M', 'N':
+			digits = append(digits, '6')
+		case 'R':
+			digits = append(digits, '7')
+		case 'S', 'Z':
+			digits = append(digits, '8')
+		}
+	}
+
+	// the repeated digits are collapsed, then the zeros are removed but the leading one
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && d == digits[i-1] {
+			continue
+		}
+		if d == '0' && b.Len() > 0 {
+			continue
+		}
+
+		b.WriteByte(d)
+	}
+
+	return b.String()
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"strconv"
 	"strings"
+
+	"github.com/tomekwlod/okpii/country"
 )
 
 func Deployments(str string) (deployments []string, err error) {
@@ -26,10 +28,19 @@ func Deployments(str string) (deployments []string, err error) {
 	return
 }
 func Countries(str string) (countries []string, err error) {
-	// Germany, Poland, switzerland
+	// Germany, Poland, switzerland  or  DEU,PL,Schweiz
+
+	if str == "" {
+		return
+	}
+
+	for _, c := range strings.Split(str, ",") {
+		name, err := country.Name(strings.TrimSpace(c))
+		if err != nil {
+			return nil, err
+		}
 
-	if str != "" {
-		countries = strings.Split(str, ",")
+		countries = append(countries, name)
 	}
 
 	return